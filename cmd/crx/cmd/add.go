@@ -10,44 +10,112 @@ import (
 	"github.com/sivchari/crx/internal/registry"
 )
 
+// newExtension holds the flags of the entry being added.
+var newExtension config.Extension
+
 var addCmd = &cobra.Command{
-	Use:   "add <extension>",
+	Use:   "add <extension> | --id <id>",
 	Short: "Add an extension to the configuration",
-	Long:  `Adds the specified extension to your configuration file.`,
-	Args:  cobra.ExactArgs(1),
-	Run:   runAdd,
+	Long: `Adds the specified extension to your configuration file.
+
+Extensions that are not in any registry can be added by ID with --id,
+optionally with a --name and the --update-url of a self-hosted extension.
+
+--mode overrides settings.mode for the extension. Running add with --mode
+for an extension that is already configured changes its mode.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if newExtension.ID != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		if cmd.Flags().Changed("name") || cmd.Flags().Changed("update-url") {
+			return fmt.Errorf("--name and --update-url require --id")
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	Run: runAdd,
 }
 
-func runAdd(cmd *cobra.Command, args []string) {
-	name := args[0]
-	logger.Debug("adding extension", "name", name)
+func init() {
+	addCmd.Flags().StringVar(&newExtension.ID, "id", "", "Add an extension by ID instead of from a registry")
+	addCmd.Flags().StringVar(&newExtension.Name, "name", "", "Name of the extension added with --id")
+	addCmd.Flags().StringVar(&newExtension.UpdateURL, "update-url", "", "Update URL of the extension added with --id")
+	addCmd.Flags().StringVar(&newExtension.Mode, "mode", "", "Install mode for this extension (force_install, normal_install, allowed)")
+	addRegistryFlag(addCmd)
+}
 
+func runAdd(cmd *cobra.Command, args []string) {
 	cfg, err := config.Load()
 	if err != nil {
 		exitWithError("Failed to load configuration", err)
 	}
 
+	ext := newExtension
+	if ext.ID != "" {
+		logger.Debug("adding extension by ID", "id", ext.ID, "name", ext.Name, "mode", ext.Mode)
+		addExtension(cfg, ext, ext.Key())
+		return
+	}
+
+	name := args[0]
+	logger.Debug("adding extension", "name", name, "mode", ext.Mode)
+
 	logger.Debug("verifying package in registry")
-	pkg, err := verifyPackage(name)
+	pkg, err := verifyPackage(cfg, name)
 	if err != nil {
 		exitWithError("Extension not found in registry", err)
 	}
-	logger.Debug("package found", "id", pkg.ID, "display_name", pkg.DisplayName)
+	logger.Debug("package found", "id", pkg.ID, "display_name", pkg.DisplayName, "registry", pkg.Registry)
+
+	if pkg.Name != name {
+		fmt.Printf("%s is an alias for %s\n", name, pkg.Name)
+		name = pkg.Name
+	}
+	if notice := pkg.DeprecationNotice(); notice != "" {
+		logger.Warn(notice)
+	}
+
+	ext.Name = name
+	addExtension(cfg, ext, fmt.Sprintf("%s (%s)", pkg.DisplayName, name))
+}
 
-	if cfg.AddExtension(name) {
-		if err := cfg.Save(); err != nil {
-			exitWithError("Failed to save configuration", err)
+// addExtension adds ext to the configuration and saves it. If the extension
+// is already configured, only its mode is updated. label names the
+// extension in messages.
+func addExtension(cfg *config.Config, ext config.Extension, label string) {
+	added := cfg.AddExtension(ext)
+	if !added {
+		existing := cfg.FindExtension(ext.Key())
+		if existing == nil {
+			existing = cfg.FindExtension(ext.ID)
 		}
-		logger.Debug("extension added to configuration")
-		fmt.Printf("Added extension: %s (%s)\n", pkg.DisplayName, name)
-	} else {
-		logger.Debug("extension already exists in configuration")
-		fmt.Printf("Extension already exists: %s\n", name)
+		if ext.Mode == "" || existing.Mode == ext.Mode {
+			logger.Debug("extension already exists in configuration")
+			fmt.Printf("Extension already exists: %s\n", existing.Key())
+			return
+		}
+		existing.Mode = ext.Mode
+		label = existing.Key()
+	}
+
+	if err := cfg.Validate(); err != nil {
+		exitWithError("Invalid extension", err)
+	}
+	if err := cfg.Save(); err != nil {
+		exitWithError("Failed to save configuration", err)
+	}
+	logger.Debug("configuration saved", "added", added)
+
+	switch {
+	case !added:
+		fmt.Printf("Changed mode of %s to %s\n", label, ext.Mode)
+	case ext.Mode != "":
+		fmt.Printf("Added extension: %s, mode %s\n", label, ext.Mode)
+	default:
+		fmt.Printf("Added extension: %s\n", label)
 	}
 }
 
-// verifyPackage checks if a package exists in the registry.
-func verifyPackage(name string) (*registry.Package, error) {
-	fetcher := registry.NewDefaultFetcher()
-	return fetcher.FetchPackage(name)
+// verifyPackage checks if a package exists in the configured registries.
+func verifyPackage(cfg *config.Config, name string) (*registry.Package, error) {
+	return newResolver(cfg).FetchPackage(name)
 }
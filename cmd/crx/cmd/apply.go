@@ -1,18 +1,30 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
 	"runtime"
+	"slices"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 
+	"github.com/sivchari/crx/internal/browser"
 	"github.com/sivchari/crx/internal/config"
+	"github.com/sivchari/crx/internal/lock"
 	"github.com/sivchari/crx/internal/logger"
 	"github.com/sivchari/crx/internal/policy"
+	"github.com/sivchari/crx/internal/profile"
 	"github.com/sivchari/crx/internal/registry"
 )
 
-var dryRun bool
+var (
+	dryRun bool
+	frozen bool
+)
 
 var applyCmd = &cobra.Command{
 	Use:   "apply",
@@ -23,54 +35,100 @@ var applyCmd = &cobra.Command{
 
 func init() {
 	applyCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show changes without applying")
+	applyCmd.Flags().BoolVar(&frozen, "frozen", false, "Apply only what is pinned in crx.lock")
+	addRegistryFlag(applyCmd)
 }
 
 func runApply(cmd *cobra.Command, args []string) {
-	logger.Debug("applying configuration", "dry_run", dryRun)
+	logger.Debug("applying configuration", "dry_run", dryRun, "frozen", frozen)
 
 	cfg, err := config.Load()
 	if err != nil {
 		exitWithError("Failed to load configuration", err)
 	}
 
-	if len(cfg.Extensions) == 0 {
+	if len(cfg.Extensions) == 0 && len(cfg.Block) == 0 {
 		fmt.Println("No extensions configured.")
 		return
 	}
-	logger.Debug("extensions loaded", "count", len(cfg.Extensions))
+	logger.Debug("extensions loaded", "count", len(cfg.Extensions), "blocked", len(cfg.Block))
 
-	// Load packages from registry
-	packages, err := loadPackages(cfg.Extensions)
-	if err != nil {
-		exitWithError("Failed to load packages", err)
+	// Load packages from the lockfile or the registry
+	var packages, blocked []*registry.Package
+	if frozen {
+		packages, blocked, err = loadLockedPackages(cfg)
+		if err != nil {
+			exitWithError("Failed to load locked packages", err)
+		}
+		logger.Debug("packages loaded from lockfile", "count", len(packages))
+	} else {
+		resolver := newResolver(cfg)
+		packages, err = loadPackages(cfg, resolver)
+		if err != nil {
+			exitWithError("Failed to load packages", err)
+		}
+		blocked, err = loadBlocked(cfg, resolver)
+		if err != nil {
+			exitWithError("Failed to load blocked packages", err)
+		}
+		logger.Debug("packages fetched from registry", "count", len(packages), "blocked", len(blocked))
+		warnLockDrift(cfg.ExtensionNames(), packages)
 	}
-	logger.Debug("packages fetched from registry", "count", len(packages))
+	warnDeprecated(packages)
 
 	// Generate policy
-	gen := policy.NewGenerator(cfg, packages)
-	pol, err := gen.Generate()
+	gen := policy.NewGenerator(cfg, packages, blocked)
+	policies, err := gen.Generate()
 	if err != nil {
 		exitWithError("Failed to generate policy", err)
 	}
-	logger.Debug("policy generated", "mode", cfg.Settings.Mode)
+	logger.Debug("policy generated", "mode", cfg.Settings.Mode, "stores", len(policies))
+
+	browsers, err := cfg.EffectiveBrowsers()
+	if err != nil {
+		exitWithError("Invalid browsers", err)
+	}
+	stale, err := policy.Stale(browsers)
+	if err != nil {
+		exitWithError("Failed to read the policies of other browsers", err)
+	}
+	logger.Debug("browsers no longer configured", "count", len(stale))
 
 	if dryRun {
-		json, err := gen.ToJSON(pol)
-		if err != nil {
-			exitWithError("Failed to generate JSON", err)
+		printPolicies(gen, policies, browsers)
+		if len(stale) > 0 {
+			fmt.Println("\nPolicy would be removed from browsers no longer configured:")
+			printLocations(stale)
+		}
+		if cfg.Settings.Lockdown {
+			reportDisabled(browsers, policies)
 		}
-		fmt.Println("Generated policy (dry-run):")
-		fmt.Println(json)
 		return
 	}
 
 	// Apply policy using OS-specific method
-	if err := gen.Apply(pol); err != nil {
+	if err := gen.Apply(policies); err != nil {
 		exitWithError("Failed to apply policy", err)
 	}
-	logger.Debug("policy applied", "path", policy.GetPolicyPath())
 
-	fmt.Printf("Policy applied to: %s\n", policy.GetPolicyPath())
+	fmt.Println("Policy applied to:")
+	firefox := false
+	for _, b := range browsers {
+		logger.Debug("policy applied", "browser", b.Name, "path", policy.Location(b))
+		fmt.Printf("  %s: %s\n", b.DisplayName, policy.Location(b))
+		firefox = firefox || b.IsFirefox()
+	}
+	if len(stale) > 0 {
+		fmt.Println("Policy removed from browsers no longer configured:")
+		printLocations(stale)
+	}
+
+	if firefox {
+		fmt.Println("Restart Firefox to apply changes; check about:policies.")
+		if len(browsers) == 1 {
+			return
+		}
+	}
 
 	switch runtime.GOOS {
 	case "darwin":
@@ -83,18 +141,186 @@ func runApply(cmd *cobra.Command, args []string) {
 	}
 }
 
-// loadPackages loads packages from the registry.
-func loadPackages(extensions []string) ([]*registry.Package, error) {
-	packages := make([]*registry.Package, 0, len(extensions))
-	fetcher := registry.NewDefaultFetcher()
+// printLocations prints the policy location of each browser.
+func printLocations(browsers []browser.Browser) {
+	for _, b := range browsers {
+		fmt.Printf("  %s: %s\n", b.DisplayName, policy.Location(b))
+	}
+}
+
+// printPolicies prints the generated policies. Browsers installing from
+// different extension stores get separate policies.
+func printPolicies(gen *policy.Generator, policies policy.Policies, browsers []browser.Browser) {
+	var printed []*policy.Policy
+	for _, b := range browsers {
+		pol := policies.For(b)
+		if slices.Contains(printed, pol) {
+			continue
+		}
+		printed = append(printed, pol)
+
+		json, err := gen.ToJSON(pol)
+		if err != nil {
+			exitWithError("Failed to generate JSON", err)
+		}
+		if len(policies) == 1 {
+			fmt.Println("Generated policy (dry-run):")
+		} else {
+			var names []string
+			for _, other := range browsers {
+				if other.Store == b.Store {
+					names = append(names, other.DisplayName)
+				}
+			}
+			fmt.Printf("Generated policy for %s (dry-run):\n", strings.Join(names, ", "))
+		}
+		fmt.Println(json)
+	}
+}
+
+// reportDisabled lists the installed extensions that the policies would
+// disable in the configured browsers.
+func reportDisabled(browsers []browser.Browser, policies policy.Policies) {
+	installed, err := profile.Installed(browsers)
+	if err != nil {
+		logger.Warn("failed to list installed extensions", "error", err)
+		return
+	}
+
+	var disabled []profile.Extension
+	for _, ext := range installed {
+		b, err := browser.Lookup(ext.Browser)
+		if err != nil {
+			continue
+		}
+		if !policies.For(b).Allows(ext.ID) {
+			disabled = append(disabled, ext)
+		}
+	}
+	logger.Debug("installed extensions checked", "installed", len(installed), "disabled", len(disabled))
+
+	if len(disabled) == 0 {
+		fmt.Println("\nLockdown: no installed extensions would be disabled.")
+		return
+	}
+
+	fmt.Printf("\nLockdown: %d installed extension(s) would be disabled:\n", len(disabled))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, ext := range disabled {
+		_, _ = fmt.Fprintf(w, "  - %s\t%s\t%s/%s\n", ext.ID, ext.Name, ext.Browser, ext.Profile)
+	}
+	_ = w.Flush()
+}
+
+// loadPackages loads the configured extensions, in the order they are
+// configured. Registry entries are resolved with resolver and direct
+// entries are used as they are.
+func loadPackages(cfg *config.Config, resolver *registry.Resolver) ([]*registry.Package, error) {
+	packages := make([]*registry.Package, len(cfg.Extensions))
+	var names []string
+	var positions []int
+	for i, ext := range cfg.Extensions {
+		if ext.IsDirect() {
+			packages[i] = ext.Package()
+			continue
+		}
+		names = append(names, ext.Name)
+		positions = append(positions, i)
+	}
 
-	for _, name := range extensions {
-		pkg, err := fetcher.FetchPackage(name)
+	if len(names) > 0 {
+		resolved, err := registry.FetchPackages(resolver, names)
 		if err != nil {
-			return nil, fmt.Errorf("failed to fetch package %s: %w", name, err)
+			return nil, err
+		}
+		for i, pkg := range resolved {
+			packages[positions[i]] = pkg
 		}
-		packages = append(packages, pkg)
+	}
+
+	for _, pkg := range packages {
+		logger.Debug("package resolved", "name", pkg.Name, "id", pkg.ID, "registry", pkg.Registry)
 	}
 
 	return packages, nil
 }
+
+// loadBlocked loads the packages of the block list, in order. Extension
+// IDs are used as they are and names are resolved with resolver.
+func loadBlocked(cfg *config.Config, resolver *registry.Resolver) ([]*registry.Package, error) {
+	return resolveBlocked(cfg, func(names []string) ([]*registry.Package, error) {
+		return registry.FetchPackages(resolver, names)
+	})
+}
+
+// resolveBlocked builds the packages of the block list, resolving names
+// with fetch.
+func resolveBlocked(cfg *config.Config, fetch func(names []string) ([]*registry.Package, error)) ([]*registry.Package, error) {
+	var resolved []*registry.Package
+	if names := cfg.BlockedNames(); len(names) > 0 {
+		var err error
+		if resolved, err = fetch(names); err != nil {
+			return nil, err
+		}
+	}
+
+	blocked := make([]*registry.Package, 0, len(cfg.Block))
+	for _, entry := range cfg.Block {
+		if config.IsExtensionID(entry) {
+			blocked = append(blocked, &registry.Package{Name: entry, ID: entry, DisplayName: entry})
+			continue
+		}
+		blocked = append(blocked, resolved[0])
+		resolved = resolved[1:]
+	}
+	return blocked, nil
+}
+
+// loadLockedPackages loads the configured and blocked extensions from
+// crx.lock.
+func loadLockedPackages(cfg *config.Config) ([]*registry.Package, []*registry.Package, error) {
+	lf, err := lock.Load()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("%s not found; run 'crx lock' first", lock.FileName)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	packages, err := lf.Packages(cfg.Extensions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w; run 'crx lock' to update it", err)
+	}
+	blocked, err := resolveBlocked(cfg, lf.BlockedPackages)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w; run 'crx lock' to update it", err)
+	}
+	return packages, blocked, nil
+}
+
+// warnLockDrift warns when packages resolve differently from crx.lock.
+// packages must be in the order of names.
+func warnLockDrift(names []string, packages []*registry.Package) {
+	lf, err := lock.Load()
+	if err != nil {
+		return
+	}
+	for i, pkg := range packages {
+		e, ok := lf.Find(names[i])
+		if !ok {
+			continue
+		}
+		if e.ID != pkg.ID || (e.Digest != "" && pkg.Digest != "" && e.Digest != pkg.Digest) {
+			logger.Warn("package differs from crx.lock", "name", names[i], "locked_id", e.ID, "id", pkg.ID)
+		}
+	}
+}
+
+// warnDeprecated warns about deprecated packages.
+func warnDeprecated(packages []*registry.Package) {
+	for _, pkg := range packages {
+		if notice := pkg.DeprecationNotice(); notice != "" {
+			logger.Warn(notice)
+		}
+	}
+}
@@ -18,9 +18,18 @@ var browseCmd = &cobra.Command{
 	Run:   runBrowse,
 }
 
+func init() {
+	addRegistryFlag(browseCmd)
+}
+
 func runBrowse(cmd *cobra.Command, args []string) {
+	cfg, err := config.Load()
+	if err != nil {
+		exitWithError("Failed to load configuration", err)
+	}
+
 	logger.Debug("fetching packages from registry")
-	packages, err := fetchAllPackages()
+	packages, err := fetchAllPackages(cfg)
 	if err != nil {
 		exitWithError("Failed to fetch packages", err)
 	}
@@ -43,14 +52,9 @@ func runBrowse(cmd *cobra.Command, args []string) {
 	}
 
 	// Add selected extensions to config
-	cfg, err := config.Load()
-	if err != nil {
-		exitWithError("Failed to load configuration", err)
-	}
-
 	added := 0
 	for _, name := range selected {
-		if cfg.AddExtension(name) {
+		if cfg.AddExtension(config.Extension{Name: name}) {
 			added++
 			logger.Debug("extension added", "name", name)
 		}
@@ -67,8 +71,7 @@ func runBrowse(cmd *cobra.Command, args []string) {
 	}
 }
 
-// fetchAllPackages fetches all packages from the registry.
-func fetchAllPackages() ([]*registry.Package, error) {
-	fetcher := registry.NewDefaultFetcher()
-	return fetcher.FetchAllPackages()
+// fetchAllPackages fetches all packages from the configured registries.
+func fetchAllPackages(cfg *config.Config) ([]*registry.Package, error) {
+	return newResolver(cfg).FetchAllPackages()
 }
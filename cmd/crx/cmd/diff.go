@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+
+	"github.com/sivchari/crx/internal/browser"
+	"github.com/sivchari/crx/internal/config"
+	"github.com/sivchari/crx/internal/logger"
+	"github.com/sivchari/crx/internal/policy"
+	"github.com/sivchari/crx/internal/registry"
+)
+
+var (
+	diffJSON     bool
+	diffExitCode bool
+)
+
+// Styles of the diff lines. lipgloss drops the colors when stdout is not
+// a terminal.
+var (
+	addedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	removedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	changedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+	headerStyle  = lipgloss.NewStyle().Bold(true)
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show how the applied policy differs from the configuration",
+	Long: `Compares the policy installed on this machine with the policy 'crx apply'
+would write, and lists the extensions that would be added, removed or change
+install mode or settings, such as the update URL or toolbar pin. Changes to
+the defaults for other extensions, like the lockdown, are listed as well, and
+so is the policy of browsers that are no longer configured, which 'crx apply'
+removes.`,
+	Args: cobra.NoArgs,
+	Run:  runDiff,
+}
+
+func init() {
+	diffCmd.Flags().BoolVar(&diffJSON, "json", false, "Output as JSON")
+	diffCmd.Flags().BoolVar(&diffExitCode, "exit-code", false, "Exit with status 1 if the applied policy differs")
+	addRegistryFlag(diffCmd)
+}
+
+// policyDiff is the diff of the policy at one location, shared by the
+// browsers reading it.
+type policyDiff struct {
+	Browsers []string          `json:"browsers"`
+	Location string            `json:"location"`
+	Changes  []extensionChange `json:"changes"`
+}
+
+// extensionChange is a change with the display name of the extension.
+type extensionChange struct {
+	policy.Change
+	Name string `json:"name,omitempty"`
+}
+
+func runDiff(cmd *cobra.Command, args []string) {
+	cfg, err := config.Load()
+	if err != nil {
+		exitWithError("Failed to load configuration", err)
+	}
+
+	resolver := newResolver(cfg)
+	packages, err := loadPackages(cfg, resolver)
+	if err != nil {
+		exitWithError("Failed to load packages", err)
+	}
+	blocked, err := loadBlocked(cfg, resolver)
+	if err != nil {
+		exitWithError("Failed to load blocked packages", err)
+	}
+
+	policies, err := policy.NewGenerator(cfg, packages, blocked).Generate()
+	if err != nil {
+		exitWithError("Failed to generate policy", err)
+	}
+
+	browsers, err := cfg.EffectiveBrowsers()
+	if err != nil {
+		exitWithError("Invalid browsers", err)
+	}
+
+	var diffs []*policyDiff
+	for _, b := range browsers {
+		location := policy.Location(b)
+		if i := slices.IndexFunc(diffs, func(d *policyDiff) bool { return d.Location == location }); i >= 0 {
+			diffs[i].Browsers = append(diffs[i].Browsers, b.Name)
+			continue
+		}
+
+		installed, err := policy.ReadInstalled(b)
+		if err != nil {
+			exitWithError("Failed to read the applied policy", err)
+		}
+		d := &policyDiff{Browsers: []string{b.Name}, Location: location, Changes: []extensionChange{}}
+		for _, c := range policy.Diff(installed, policies.For(b)) {
+			d.Changes = append(d.Changes, extensionChange{Change: c})
+		}
+		logger.Debug("policy compared", "browser", b.Name, "location", location, "changes", len(d.Changes))
+		diffs = append(diffs, d)
+	}
+
+	// crx apply removes the policy of browsers that are no longer
+	// configured.
+	stale, err := policy.Stale(browsers)
+	if err != nil {
+		exitWithError("Failed to read the policies of other browsers", err)
+	}
+	for _, b := range stale {
+		installed, err := policy.ReadInstalled(b)
+		if err != nil {
+			exitWithError("Failed to read the applied policy", err)
+		}
+		d := &policyDiff{Browsers: []string{b.Name}, Location: policy.Location(b), Changes: []extensionChange{}}
+		for _, c := range policy.Diff(installed, &policy.Policy{}) {
+			d.Changes = append(d.Changes, extensionChange{Change: c})
+		}
+		logger.Debug("policy of a browser no longer configured compared", "browser", b.Name, "changes", len(d.Changes))
+		diffs = append(diffs, d)
+	}
+
+	names := newDisplayNames(resolver, slices.Concat(browsers, stale))
+	names.add(packages)
+	names.add(blocked)
+	drift := false
+	for _, d := range diffs {
+		for i := range d.Changes {
+			if d.Changes[i].ID != policy.DefaultSettingsKey {
+				d.Changes[i].Name = names.lookup(d.Changes[i].ID)
+			}
+		}
+		drift = drift || len(d.Changes) > 0
+	}
+
+	if diffJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(diffs); err != nil {
+			exitWithError("Failed to encode JSON", err)
+		}
+	} else {
+		printDiffs(diffs)
+	}
+
+	if diffExitCode && drift {
+		os.Exit(1)
+	}
+}
+
+func printDiffs(diffs []*policyDiff) {
+	for i, d := range diffs {
+		if i > 0 {
+			fmt.Println()
+		}
+		var names []string
+		for _, name := range d.Browsers {
+			b, _ := browser.Lookup(name)
+			names = append(names, b.DisplayName)
+		}
+		fmt.Println(headerStyle.Render(fmt.Sprintf("%s (%s)", strings.Join(names, ", "), d.Location)))
+
+		if len(d.Changes) == 0 {
+			fmt.Println("  No changes")
+			continue
+		}
+
+		// Align the columns first; the escape codes of the styles would
+		// throw off the widths.
+		var buf bytes.Buffer
+		w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		styles := make([]lipgloss.Style, len(d.Changes))
+		for i, c := range d.Changes {
+			label := c.ID
+			switch {
+			case c.ID == policy.DefaultSettingsKey:
+				label = fmt.Sprintf("defaults (%s)", c.ID)
+			case c.Name != "":
+				label = fmt.Sprintf("%s (%s)", c.Name, c.ID)
+			}
+			switch c.Kind {
+			case policy.ChangeAdded:
+				_, _ = fmt.Fprintf(w, "  + %s\t%s\n", label, c.To)
+				styles[i] = addedStyle
+			case policy.ChangeRemoved:
+				_, _ = fmt.Fprintf(w, "  - %s\t%s\n", label, c.From)
+				styles[i] = removedStyle
+			case policy.ChangeModeChanged:
+				_, _ = fmt.Fprintf(w, "  ~ %s\t%s -> %s\n", label, c.From, c.To)
+				styles[i] = changedStyle
+			case policy.ChangeSettingsChanged:
+				_, _ = fmt.Fprintf(w, "  ~ %s\t%s changed\n", label, strings.Join(c.Fields, ", "))
+				styles[i] = changedStyle
+			}
+		}
+		_ = w.Flush()
+
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		for i, line := range lines {
+			fmt.Println(styles[i].Render(line))
+		}
+	}
+}
+
+// displayNames resolves extension IDs of any store to display names.
+type displayNames struct {
+	resolver *registry.Resolver
+	browsers []browser.Browser
+	names    map[string]string
+	// fetched is set once every registry package has been added.
+	fetched bool
+}
+
+func newDisplayNames(resolver *registry.Resolver, browsers []browser.Browser) *displayNames {
+	return &displayNames{resolver: resolver, browsers: browsers, names: make(map[string]string)}
+}
+
+// add adds the IDs of packages in the stores of the browsers.
+func (n *displayNames) add(packages []*registry.Package) {
+	for _, pkg := range packages {
+		for _, b := range n.browsers {
+			if id := storeID(pkg, b); id != "" && pkg.DisplayName != id {
+				n.names[id] = pkg.DisplayName
+			}
+		}
+	}
+}
+
+// lookup returns the display name of id, searching the registries for
+// extensions that are not configured. It returns an empty string if the
+// extension is unknown.
+func (n *displayNames) lookup(id string) string {
+	if name, ok := n.names[id]; ok || n.fetched {
+		return name
+	}
+
+	n.fetched = true
+	packages, err := n.resolver.FetchAllPackages()
+	if err != nil {
+		logger.Debug("failed to fetch packages for display names", "error", err)
+		return ""
+	}
+	n.add(packages)
+	return n.names[id]
+}
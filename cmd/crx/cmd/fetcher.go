@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/sivchari/crx/internal/config"
+	"github.com/sivchari/crx/internal/logger"
+	"github.com/sivchari/crx/internal/registry"
+)
+
+// registryPath overrides the configured registries with a local directory.
+var registryPath string
+
+// addRegistryFlag registers the --registry flag on cmd.
+func addRegistryFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&registryPath, "registry", "", "Use the local registry at the given path instead of the configured registries")
+}
+
+// newResolver creates a Resolver for the registries configured in cfg,
+// or for the local registry given with --registry. A command creates one
+// resolver and passes it down, so that registry files are fetched and
+// tokens looked up once per command.
+func newResolver(cfg *config.Config) *registry.Resolver {
+	regs := effectiveRegistries(cfg)
+	sources := make([]registry.Source, 0, len(regs))
+	for _, r := range regs {
+		sources = append(sources, registry.Source{
+			Name:    r.Name,
+			Fetcher: newFetcher(r),
+		})
+	}
+	return registry.NewResolver(sources...)
+}
+
+// effectiveRegistries returns the registries to use in priority order,
+// honoring the --registry flag.
+func effectiveRegistries(cfg *config.Config) []config.Registry {
+	if registryPath != "" {
+		return []config.Registry{{
+			Name: "local",
+			Type: config.RegistryTypeLocal,
+			Path: registryPath,
+		}}
+	}
+	return cfg.EffectiveRegistries()
+}
+
+// newFetcher creates a Fetcher for a single registry.
+func newFetcher(r config.Registry) registry.Fetcher {
+	opts := []registry.Option{
+		registry.WithOffline(offline),
+		registry.WithPublicKeys(r.PublicKeys...),
+	}
+
+	switch r.Type {
+	case config.RegistryTypeLocal:
+		logger.Debug("using local registry", "name", r.Name, "path", r.Path, "signed", len(r.PublicKeys) > 0)
+		return registry.NewLocalFetcher(r.Path, opts...)
+	default:
+		token := registry.GitHubToken(r.TokenEnv)
+		opts = append(opts, registry.WithToken(token))
+		logger.Debug("using github registry", "name", r.Name, "repo", r.Repo, "ref", r.Ref, "signed", len(r.PublicKeys) > 0, "authenticated", token != "")
+		return registry.NewGitHubFetcher(r.Repo, r.Ref, opts...)
+	}
+}
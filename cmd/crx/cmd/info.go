@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sivchari/crx/internal/browser"
+	"github.com/sivchari/crx/internal/config"
+	"github.com/sivchari/crx/internal/logger"
+	"github.com/sivchari/crx/internal/policy"
+	"github.com/sivchari/crx/internal/registry"
+)
+
+var infoJSON bool
+
+var infoCmd = &cobra.Command{
+	Use:   "info <name|id>",
+	Short: "Show details of an extension",
+	Long: `Shows the registry definition of an extension together with its
+install state: whether it is configured, the install mode it gets, and
+whether it appears in the policy currently applied to the system. The
+extension can be given by name, alias, or the ID of any of its store
+listings, including its Firefox add-on ID.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runInfo,
+}
+
+func init() {
+	infoCmd.Flags().BoolVar(&infoJSON, "json", false, "Output as JSON")
+	addRegistryFlag(infoCmd)
+}
+
+// extensionInfo is the output of crx info.
+type extensionInfo struct {
+	Package *registry.Package `json:"package"`
+	// Configured reports whether the extension is in the configuration.
+	Configured bool `json:"configured"`
+	// Mode is the install mode crx apply uses for the extension.
+	Mode string `json:"mode,omitempty"`
+	// Installed maps each configured browser to the install mode found in
+	// its applied policy. Browsers whose policy does not mention the
+	// extension are omitted.
+	Installed map[string]string `json:"installed,omitempty"`
+}
+
+func runInfo(cmd *cobra.Command, args []string) {
+	query := args[0]
+	logger.Debug("showing extension info", "query", query)
+
+	cfg, err := config.Load()
+	if err != nil {
+		exitWithError("Failed to load configuration", err)
+	}
+
+	pkg, err := lookupPackage(cfg, query)
+	if err != nil {
+		exitWithError("Extension not found in registry", err)
+	}
+	logger.Debug("package found", "name", pkg.Name, "registry", pkg.Registry)
+
+	i := slices.IndexFunc(cfg.Extensions, func(ext config.Extension) bool {
+		if ext.IsDirect() {
+			return ext.ID == pkg.ID
+		}
+		return ext.Name == pkg.Name || pkg.HasAlias(ext.Name)
+	})
+	info := extensionInfo{
+		Package:    pkg,
+		Configured: i >= 0,
+	}
+	if info.Configured {
+		info.Mode = cfg.ModeFor(cfg.Extensions[i])
+	}
+
+	browsers, err := cfg.EffectiveBrowsers()
+	if err != nil {
+		exitWithError("Invalid browsers", err)
+	}
+	for _, b := range browsers {
+		installed, err := policy.ReadInstalled(b)
+		if err != nil {
+			logger.Warn("failed to read the applied policy", "browser", b.Name, "error", err)
+			continue
+		}
+		if mode := installed.InstallMode(storeID(pkg, b)); mode != "" {
+			if info.Installed == nil {
+				info.Installed = make(map[string]string)
+			}
+			info.Installed[b.Name] = mode
+		}
+	}
+
+	if infoJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(info); err != nil {
+			exitWithError("Failed to encode JSON", err)
+		}
+		return
+	}
+
+	printInfo(&info)
+}
+
+// storeID returns the ID of the package in the store of the browser.
+func storeID(pkg *registry.Package, b browser.Browser) string {
+	if b.IsFirefox() {
+		if pkg.Firefox == nil {
+			return ""
+		}
+		return pkg.Firefox.AddonID
+	}
+	listing, _ := pkg.Listing(b.Store)
+	return listing.ID
+}
+
+// lookupPackage finds a package by name, alias or extension ID. The ID
+// may be that of any store listing, including a Firefox add-on ID.
+// Direct entries in the configuration are found as well.
+func lookupPackage(cfg *config.Config, query string) (*registry.Package, error) {
+	if ext := cfg.FindExtension(query); ext != nil && ext.IsDirect() {
+		return ext.Package(), nil
+	}
+
+	resolver := newResolver(cfg)
+	if registry.ValidateName(query) == nil && registry.ValidateID(query) != nil {
+		return resolver.FetchPackage(query)
+	}
+
+	packages, err := resolver.FetchAllPackages()
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range packages {
+		if hasID(pkg, query) {
+			return pkg, nil
+		}
+	}
+	return nil, fmt.Errorf("no package with ID %s: %w", query, registry.ErrNotFound)
+}
+
+// hasID reports whether id is the ID of the package or of one of its store
+// listings.
+func hasID(pkg *registry.Package, id string) bool {
+	if pkg.ID == id {
+		return true
+	}
+	for _, name := range browser.Names() {
+		b, _ := browser.Lookup(name)
+		if storeID(pkg, b) == id {
+			return true
+		}
+	}
+	return false
+}
+
+func printInfo(info *extensionInfo) {
+	pkg := info.Package
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	row := func(label, value string) {
+		if value != "" {
+			_, _ = fmt.Fprintf(w, "%s:\t%s\n", label, value)
+		}
+	}
+	row("Name", pkg.Name)
+	row("Display name", pkg.DisplayName)
+	row("ID", pkg.ID)
+	if pkg.ChromeWebStore != nil {
+		row("Chrome Web Store ID", pkg.ChromeWebStore.ID)
+	}
+	if pkg.EdgeAddons != nil {
+		row("Edge Add-ons ID", pkg.EdgeAddons.ID)
+	}
+	if pkg.Firefox != nil {
+		row("Firefox add-on ID", pkg.Firefox.AddonID)
+	}
+	row("Description", pkg.Description)
+	row("Homepage", pkg.Homepage)
+	row("Repository", pkg.Repository)
+	row("Tags", strings.Join(pkg.Tags, ", "))
+	row("Aliases", strings.Join(pkg.Aliases, ", "))
+	row("Registry", pkg.Registry)
+	row("Digest", pkg.Digest)
+	if notice := pkg.DeprecationNotice(); notice != "" {
+		row("Deprecated", notice)
+	}
+
+	configured := "no"
+	if info.Configured {
+		configured = "yes"
+	}
+	row("Configured", configured)
+	row("Install mode", info.Mode)
+
+	installed := "no"
+	if len(info.Installed) > 0 {
+		var modes []string
+		for _, name := range slices.Sorted(maps.Keys(info.Installed)) {
+			modes = append(modes, fmt.Sprintf("%s (%s)", name, info.Installed[name]))
+		}
+		installed = strings.Join(modes, ", ")
+	}
+	row("In applied policy", installed)
+	_ = w.Flush()
+}
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 
@@ -16,6 +18,10 @@ var listCmd = &cobra.Command{
 	Run:   runList,
 }
 
+func init() {
+	addRegistryFlag(listCmd)
+}
+
 func runList(cmd *cobra.Command, args []string) {
 	logger.Debug("listing configured extensions")
 
@@ -25,14 +31,37 @@ func runList(cmd *cobra.Command, args []string) {
 	}
 	logger.Debug("configuration loaded", "extensions", len(cfg.Extensions))
 
-	if len(cfg.Extensions) == 0 {
+	if len(cfg.Extensions) == 0 && len(cfg.Block) == 0 {
 		fmt.Println("No extensions configured.")
 		fmt.Println("Use 'crx add <extension>' to add extensions.")
 		return
 	}
 
+	// Registry lookups only add deprecation notices, so failures are not fatal.
+	packages, err := loadPackages(cfg, newResolver(cfg))
+	if err != nil {
+		logger.Debug("failed to load packages, skipping deprecation check", "error", err)
+	}
+
 	fmt.Println("Configured extensions:")
-	for _, ext := range cfg.Extensions {
-		fmt.Printf("  - %s\n", ext)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, ext := range cfg.Extensions {
+		name := ext.Key()
+		switch {
+		case ext.IsDirect() && ext.Name != "":
+			name += " (" + ext.ID + ")"
+		case packages != nil && packages[i].Deprecated:
+			name += " (deprecated)"
+		}
+		_, _ = fmt.Fprintf(w, "  - %s\t%s\n", name, cfg.ModeFor(ext))
+	}
+	_ = w.Flush()
+
+	if len(cfg.Block) > 0 {
+		fmt.Println("Blocked extensions:")
+		for _, entry := range cfg.Block {
+			fmt.Printf("  - %s\n", entry)
+		}
 	}
+	warnDeprecated(packages)
 }
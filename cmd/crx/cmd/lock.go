@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sivchari/crx/internal/config"
+	"github.com/sivchari/crx/internal/lock"
+	"github.com/sivchari/crx/internal/logger"
+	"github.com/sivchari/crx/internal/registry"
+)
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Pin the configured extensions in crx.lock",
+	Long: `Resolves every configured and blocked extension against the registries
+and writes crx.lock next to config.yaml. The lockfile records each extension's ID,
+source registry, registry ref and commit, and a digest of its package file.
+Use 'crx apply --frozen' to apply exactly what is locked.`,
+	Run: runLock,
+}
+
+func init() {
+	addRegistryFlag(lockCmd)
+}
+
+func runLock(cmd *cobra.Command, args []string) {
+	logger.Debug("locking configuration")
+
+	cfg, err := config.Load()
+	if err != nil {
+		exitWithError("Failed to load configuration", err)
+	}
+
+	resolver := newResolver(cfg)
+	packages, err := loadPackages(cfg, resolver)
+	if err != nil {
+		exitWithError("Failed to load packages", err)
+	}
+	blockedNames := cfg.BlockedNames()
+	blocked, err := registry.FetchPackages(resolver, blockedNames)
+	if err != nil {
+		exitWithError("Failed to load blocked packages", err)
+	}
+
+	refs := make(map[string]string)
+	for _, r := range effectiveRegistries(cfg) {
+		if r.Type == config.RegistryTypeGitHub {
+			refs[r.Name] = r.Ref
+		}
+	}
+
+	used := make(map[string]bool)
+	for _, pkg := range packages {
+		used[pkg.Registry] = true
+	}
+	for _, pkg := range blocked {
+		used[pkg.Registry] = true
+	}
+
+	commits := make(map[string]string)
+	for _, src := range resolver.Sources() {
+		if !used[src.Name] {
+			continue
+		}
+		rev, ok := src.Fetcher.(registry.Revisioner)
+		if !ok {
+			continue
+		}
+		commit, err := rev.Revision()
+		if err != nil {
+			logger.Warn("failed to resolve registry commit", "registry", src.Name, "error", err)
+			continue
+		}
+		logger.Debug("registry commit resolved", "registry", src.Name, "commit", commit)
+		commits[src.Name] = commit
+	}
+
+	lf := &lock.Lockfile{
+		Version:    lock.Version,
+		Extensions: make([]lock.Entry, 0, len(packages)),
+	}
+	entry := func(name string, pkg *registry.Package) lock.Entry {
+		return lock.Entry{
+			Name:     name,
+			ID:       pkg.ID,
+			Registry: pkg.Registry,
+			Ref:      refs[pkg.Registry],
+			Commit:   commits[pkg.Registry],
+			Digest:   pkg.Digest,
+			Package:  *pkg,
+		}
+	}
+	for i, pkg := range packages {
+		lf.Extensions = append(lf.Extensions, entry(cfg.Extensions[i].Key(), pkg))
+	}
+	for i, pkg := range blocked {
+		lf.Blocked = append(lf.Blocked, entry(blockedNames[i], pkg))
+	}
+
+	if err := lf.Save(); err != nil {
+		exitWithError("Failed to save lockfile", err)
+	}
+
+	path, _ := lock.Path()
+	fmt.Printf("Locked %d extension(s) in %s\n", len(lf.Extensions), path)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sivchari/crx/internal/config"
+	"github.com/sivchari/crx/internal/logger"
+)
+
+var migrateDryRun bool
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Replace deprecated extensions in the configuration",
+	Long: `Rewrites the configuration so that every deprecated extension with a
+replacement in the registry is replaced by that extension.`,
+	Run: runMigrate,
+}
+
+func init() {
+	migrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Show replacements without saving")
+	addRegistryFlag(migrateCmd)
+}
+
+func runMigrate(cmd *cobra.Command, args []string) {
+	logger.Debug("migrating configuration", "dry_run", migrateDryRun)
+
+	cfg, err := config.Load()
+	if err != nil {
+		exitWithError("Failed to load configuration", err)
+	}
+
+	resolver := newResolver(cfg)
+	packages, err := loadPackages(cfg, resolver)
+	if err != nil {
+		exitWithError("Failed to load packages", err)
+	}
+
+	names := cfg.ExtensionNames()
+	migrated := 0
+	for i, pkg := range packages {
+		name := names[i]
+		if !pkg.Deprecated {
+			continue
+		}
+		if pkg.ReplacedBy == "" {
+			logger.Warn("deprecated extension has no replacement", "name", name)
+			continue
+		}
+
+		replacement, err := resolver.FetchPackage(pkg.ReplacedBy)
+		if err != nil {
+			logger.Warn("replacement not found in registry", "name", name, "replaced_by", pkg.ReplacedBy, "error", err)
+			continue
+		}
+
+		if cfg.ReplaceExtension(name, replacement.Name) {
+			migrated++
+			fmt.Printf("  %s -> %s (%s)\n", name, replacement.Name, replacement.DisplayName)
+		}
+	}
+
+	if migrated == 0 {
+		fmt.Println("No extensions to migrate.")
+		return
+	}
+
+	if migrateDryRun {
+		fmt.Printf("%d extension(s) would be migrated (dry-run).\n", migrated)
+		return
+	}
+
+	if err := cfg.Save(); err != nil {
+		exitWithError("Failed to save configuration", err)
+	}
+	fmt.Printf("Migrated %d extension(s).\n", migrated)
+	fmt.Println("Run 'crx apply' to update the policy.")
+}
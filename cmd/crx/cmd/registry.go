@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var registryCmd = &cobra.Command{
+	Use:   "registry",
+	Short: "Manage crx registries",
+	Long:  `Commands for registry maintainers to build and publish a registry.`,
+}
+
+func init() {
+	registryCmd.AddCommand(registryInitCmd)
+	registryCmd.AddCommand(registryAddPackageCmd)
+	registryCmd.AddCommand(registryBuildCmd)
+	registryCmd.AddCommand(registrySignCmd)
+	registryCmd.AddCommand(registryLintCmd)
+}
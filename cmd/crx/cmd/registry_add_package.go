@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sivchari/crx/internal/logger"
+	"github.com/sivchari/crx/internal/registry"
+)
+
+var newPackage registry.Package
+
+var registryAddPackageCmd = &cobra.Command{
+	Use:   "add-package <dir>",
+	Short: "Add a package to a registry",
+	Long: `Writes a validated package file to the pkgs directory and inserts its name
+into registry.yaml in sorted order. The bundled index is regenerated for
+version 2 registries.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runRegistryAddPackage,
+}
+
+func init() {
+	flags := registryAddPackageCmd.Flags()
+	flags.StringVar(&newPackage.ID, "id", "", "Extension ID")
+	flags.StringVar(&newPackage.Name, "name", "", "Package name")
+	flags.StringVar(&newPackage.DisplayName, "display-name", "", "Display name (defaults to the package name)")
+	flags.StringVar(&newPackage.Description, "description", "", "Short description")
+	flags.StringVar(&newPackage.Homepage, "homepage", "", "Homepage URL")
+	flags.StringVar(&newPackage.Repository, "repository", "", "Source repository URL")
+	flags.StringVar(&newPackage.UpdateURL, "update-url", "", "Update manifest URL of a self-hosted extension")
+	flags.StringSliceVar(&newPackage.Tags, "tag", nil, "Tag (can be repeated)")
+	flags.StringSliceVar(&newPackage.Aliases, "alias", nil, "Alternative name (can be repeated)")
+	_ = registryAddPackageCmd.MarkFlagRequired("id")
+	_ = registryAddPackageCmd.MarkFlagRequired("name")
+}
+
+func runRegistryAddPackage(cmd *cobra.Command, args []string) {
+	dir := args[0]
+	pkg := newPackage
+	if pkg.DisplayName == "" {
+		pkg.DisplayName = pkg.Name
+	}
+	logger.Debug("adding package to registry", "dir", dir, "name", pkg.Name, "id", pkg.ID)
+
+	if err := registry.AddPackage(dir, &pkg); err != nil {
+		exitWithError("Failed to add package", err)
+	}
+
+	fmt.Printf("Added package: %s (%s)\n", pkg.DisplayName, pkg.Name)
+	if _, err := os.Stat(filepath.Join(dir, registry.ManifestFile)); err == nil {
+		fmt.Println("Run 'crx registry sign' to update the signed manifest.")
+	}
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sivchari/crx/internal/logger"
+	"github.com/sivchari/crx/internal/registry"
+)
+
+var registryBuildCmd = &cobra.Command{
+	Use:   "build [dir]",
+	Short: "Generate the bundled registry index",
+	Long: `Generates index.yaml from the package files in the pkgs directory and
+upgrades registry.yaml to version 2. Clients fetch the whole registry from
+index.yaml in a single request. The directory defaults to the current one.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runRegistryBuild,
+}
+
+func runRegistryBuild(cmd *cobra.Command, args []string) {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	logger.Debug("building registry index", "dir", dir)
+
+	idx, err := registry.BuildIndex(dir)
+	if err != nil {
+		exitWithError("Failed to build registry index", err)
+	}
+
+	fmt.Printf("Wrote %s with %d package(s).\n", registry.IndexFile, len(idx.Packages))
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sivchari/crx/internal/logger"
+	"github.com/sivchari/crx/internal/registry"
+)
+
+var registryInitCmd = &cobra.Command{
+	Use:   "init <dir>",
+	Short: "Create a new registry",
+	Long:  `Creates registry.yaml, index.yaml and an empty pkgs directory in the given directory.`,
+	Args:  cobra.ExactArgs(1),
+	Run:   runRegistryInit,
+}
+
+func runRegistryInit(cmd *cobra.Command, args []string) {
+	dir := args[0]
+	logger.Debug("initializing registry", "dir", dir)
+
+	if err := registry.Init(dir); err != nil {
+		exitWithError("Failed to initialize registry", err)
+	}
+
+	fmt.Printf("Registry created at %s\n", dir)
+	fmt.Println("\nNext steps:")
+	fmt.Println("  1. Run 'crx registry add-package <dir> --id <id> --name <name>' to add packages")
+	fmt.Println("  2. Run 'crx registry lint <dir>' to check the registry")
+	fmt.Println("  3. Run 'crx add <name> --registry <dir>' to try it out")
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sivchari/crx/internal/logger"
+	"github.com/sivchari/crx/internal/registry"
+)
+
+var registryLintCmd = &cobra.Command{
+	Use:   "lint [dir]",
+	Short: "Check a registry for mistakes",
+	Long: `Checks registry.yaml and every package file against the registry schema.
+All problems are reported with their file and line, and the command exits
+with a non-zero status if any are found. The directory defaults to the
+current one.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runRegistryLint,
+}
+
+func runRegistryLint(cmd *cobra.Command, args []string) {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	logger.Debug("linting registry", "dir", dir)
+
+	problems, err := registry.Lint(dir)
+	if err != nil {
+		exitWithError("Failed to lint registry", err)
+	}
+
+	if len(problems) == 0 {
+		fmt.Println("No problems found.")
+		return
+	}
+
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	fmt.Printf("\n%d problem(s) found.\n", len(problems))
+	os.Exit(1)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"crypto/ed25519"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sivchari/crx/internal/logger"
+	"github.com/sivchari/crx/internal/registry"
+)
+
+var signKeyPath string
+
+var registrySignCmd = &cobra.Command{
+	Use:   "sign [dir]",
+	Short: "Sign the registry content",
+	Long: `Writes manifest.yaml with the digest of registry.yaml, index.yaml and every
+package file, and manifest.yaml.sig with an ed25519 signature of the manifest.
+The key file must be a PEM-encoded ed25519 private key, for example one
+created with "openssl genpkey -algorithm ed25519 -out key.pem".
+Run this after every change to the registry. The directory defaults to the
+current one.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runRegistrySign,
+}
+
+func init() {
+	registrySignCmd.Flags().StringVar(&signKeyPath, "key", "", "Path to the ed25519 private key file")
+	_ = registrySignCmd.MarkFlagRequired("key")
+}
+
+func runRegistrySign(cmd *cobra.Command, args []string) {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	logger.Debug("signing registry", "dir", dir)
+
+	key, err := registry.LoadPrivateKey(signKeyPath)
+	if err != nil {
+		exitWithError("Failed to load signing key", err)
+	}
+
+	manifest, err := registry.Sign(dir, key)
+	if err != nil {
+		exitWithError("Failed to sign registry", err)
+	}
+
+	fmt.Printf("Signed %d file(s) into %s.\n", len(manifest.Files), registry.ManifestFile)
+	fmt.Println("\nTrust this registry by adding its public key to config.yaml:")
+	fmt.Println("  public_keys:")
+	fmt.Printf("    - %s\n", registry.EncodePublicKey(key.Public().(ed25519.PublicKey)))
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/sivchari/crx/internal/config"
 	"github.com/sivchari/crx/internal/logger"
+	"github.com/sivchari/crx/internal/registry"
 )
 
 var removeCmd = &cobra.Command{
@@ -17,6 +18,10 @@ var removeCmd = &cobra.Command{
 	Run:   runRemove,
 }
 
+func init() {
+	addRegistryFlag(removeCmd)
+}
+
 func runRemove(cmd *cobra.Command, args []string) {
 	name := args[0]
 	logger.Debug("removing extension", "name", name)
@@ -26,12 +31,32 @@ func runRemove(cmd *cobra.Command, args []string) {
 		exitWithError("Failed to load configuration", err)
 	}
 
-	if cfg.RemoveExtension(name) {
+	// The registry is only used for display names and aliases, so a failed
+	// lookup does not prevent removal. Direct entries are not looked up.
+	var pkg *registry.Package
+	if ext := cfg.FindExtension(name); ext != nil && ext.IsDirect() {
+		name = ext.Key()
+	} else if pkg, err = newResolver(cfg).FetchPackage(name); err != nil {
+		logger.Debug("package not resolved", "error", err)
+	}
+
+	removed := cfg.RemoveExtension(name)
+	if !removed && pkg != nil && pkg.Name != name {
+		logger.Debug("resolved alias", "alias", name, "name", pkg.Name)
+		name = pkg.Name
+		removed = cfg.RemoveExtension(name)
+	}
+
+	if removed {
 		if err := cfg.Save(); err != nil {
 			exitWithError("Failed to save configuration", err)
 		}
 		logger.Debug("extension removed from configuration")
-		fmt.Printf("Removed extension: %s\n", name)
+		if pkg != nil {
+			fmt.Printf("Removed extension: %s (%s)\n", pkg.DisplayName, name)
+		} else {
+			fmt.Printf("Removed extension: %s\n", name)
+		}
 		fmt.Println("Run 'crx apply' to update the policy.")
 	} else {
 		logger.Debug("extension not found in configuration")
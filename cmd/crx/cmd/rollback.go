@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sivchari/crx/internal/config"
+	"github.com/sivchari/crx/internal/logger"
+	"github.com/sivchari/crx/internal/policy"
+)
+
+var (
+	rollbackTo   int
+	rollbackList bool
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Restore a previously applied policy",
+	Long: `Restores the policy that was in place before the last 'crx apply', or an
+older one with --to. Backups are numbered from 1, the newest; list them with
+--list. The policy being replaced is backed up as well, so a rollback can be
+undone with another 'crx rollback'.
+
+Backups are kept in $XDG_STATE_HOME/crx/backups, which defaults to
+~/.local/state/crx/backups. When run as root on Linux, as with 'sudo crx
+apply', they are kept in /var/lib/crx/backups instead.`,
+	Args: cobra.NoArgs,
+	Run:  runRollback,
+}
+
+func init() {
+	rollbackCmd.Flags().IntVar(&rollbackTo, "to", 1, "Number of the backup to restore, as shown by --list")
+	rollbackCmd.Flags().BoolVar(&rollbackList, "list", false, "List the backups")
+}
+
+func runRollback(cmd *cobra.Command, args []string) {
+	backups, err := policy.Backups()
+	if err != nil {
+		exitWithError("Failed to load backups", err)
+	}
+	logger.Debug("backups loaded", "count", len(backups))
+
+	if rollbackList {
+		printBackups(backups)
+		return
+	}
+
+	if len(backups) == 0 {
+		fmt.Println("No backups found. Backups are saved by 'crx apply'.")
+		return
+	}
+	if rollbackTo < 1 || rollbackTo > len(backups) {
+		exitWithError("Invalid backup", fmt.Errorf("--to must be between 1 and %d, got %d", len(backups), rollbackTo))
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		exitWithError("Failed to load configuration", err)
+	}
+
+	backup := backups[rollbackTo-1]
+	if err := policy.Rollback(backup, cfg.EffectiveBackups()); err != nil {
+		exitWithError("Failed to roll back", err)
+	}
+
+	fmt.Printf("Restored the policy saved at %s to:\n", backup.CreatedAt.Local().Format(time.DateTime))
+	for _, location := range backup.Locations() {
+		fmt.Printf("  %s\n", location)
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		fmt.Println("\nmacOS: Profile opened in System Settings.")
+		fmt.Println("Please click 'Install' to apply the policy.")
+	default:
+		fmt.Println("Reload policies at chrome://policy or restart the browser to apply changes.")
+	}
+}
+
+func printBackups(backups []*policy.Backup) {
+	if len(backups) == 0 {
+		fmt.Println("No backups found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "#\tSAVED\tREPLACED BY\tLOCATIONS")
+	for i, b := range backups {
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", i+1, b.CreatedAt.Local().Format(time.DateTime), b.Command, strings.Join(b.Locations(), ", "))
+	}
+	_ = w.Flush()
+}
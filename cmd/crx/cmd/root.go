@@ -2,13 +2,17 @@ package cmd
 
 import (
 	"os"
+	"strconv"
 
 	"github.com/spf13/cobra"
 
 	"github.com/sivchari/crx/internal/logger"
 )
 
-var verbose bool
+var (
+	verbose bool
+	offline bool
+)
 
 var rootCmd = &cobra.Command{
 	Use:   "crx",
@@ -19,6 +23,17 @@ It allows you to manage Chrome extensions using a YAML configuration file
 and generates Chrome Enterprise Policy JSON for installation.`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		logger.Init(verbose)
+
+		if !cmd.Flags().Changed("offline") {
+			if v, ok := os.LookupEnv("CRX_OFFLINE"); ok {
+				b, err := strconv.ParseBool(v)
+				if err != nil {
+					exitWithError("Invalid CRX_OFFLINE value", err)
+				}
+				offline = b
+			}
+		}
+		logger.Debug("offline mode", "enabled", offline)
 	},
 }
 
@@ -28,13 +43,21 @@ func Execute() error {
 
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "Use only cached or local registries (also CRX_OFFLINE)")
 
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(addCmd)
 	rootCmd.AddCommand(removeCmd)
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(applyCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(rollbackCmd)
+	rootCmd.AddCommand(lockCmd)
+	rootCmd.AddCommand(migrateCmd)
 	rootCmd.AddCommand(browseCmd)
+	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(infoCmd)
+	rootCmd.AddCommand(registryCmd)
 }
 
 func exitWithError(msg string, err error) {
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sivchari/crx/internal/config"
+	"github.com/sivchari/crx/internal/logger"
+	"github.com/sivchari/crx/internal/registry"
+)
+
+var (
+	searchTags []string
+	searchJSON bool
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search [query]",
+	Short: "Search extensions in the registry",
+	Long: `Searches the registries by name, display name, description and tags.
+Matching is case-insensitive and fuzzy, and exact name matches are listed
+first. The query may be omitted when filtering by --tag.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && len(searchTags) == 0 {
+			return fmt.Errorf("requires a query or at least one --tag")
+		}
+		return nil
+	},
+	Run: runSearch,
+}
+
+func init() {
+	searchCmd.Flags().StringSliceVar(&searchTags, "tag", nil, "Only show extensions with this tag (can be repeated)")
+	searchCmd.Flags().BoolVar(&searchJSON, "json", false, "Output results as JSON")
+	addRegistryFlag(searchCmd)
+}
+
+func runSearch(cmd *cobra.Command, args []string) {
+	query := strings.Join(args, " ")
+	logger.Debug("searching registry", "query", query, "tags", searchTags)
+
+	cfg, err := config.Load()
+	if err != nil {
+		exitWithError("Failed to load configuration", err)
+	}
+
+	results, err := newResolver(cfg).Search(query)
+	if err != nil {
+		exitWithError("Failed to search registry", err)
+	}
+	results = registry.FilterByTags(results, searchTags)
+	logger.Debug("search finished", "results", len(results))
+
+	if searchJSON {
+		if results == nil {
+			results = []*registry.Package{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			exitWithError("Failed to encode JSON", err)
+		}
+		return
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No extensions found.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tDISPLAY NAME\tDESCRIPTION")
+	for _, pkg := range results {
+		desc := pkg.Description
+		if pkg.Deprecated {
+			desc = "(deprecated) " + desc
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", pkg.Name, pkg.DisplayName, desc)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,195 @@
+// Package browser describes the browsers crx can manage.
+package browser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/sivchari/crx/internal/registry"
+)
+
+// Browser is a browser that reads Chrome Enterprise policies, or Firefox
+// with its own enterprise policies.
+type Browser struct {
+	// Name identifies the browser in the configuration, e.g. "brave".
+	Name        string
+	DisplayName string
+
+	// LinuxPolicyDir is the directory of managed JSON policies on Linux.
+	LinuxPolicyDir string
+	// MacDomain is the preference domain read on macOS.
+	MacDomain string
+	// WindowsPolicyKey is the policy key under HKEY_LOCAL_MACHINE.
+	WindowsPolicyKey string
+	// Store is the extension store the browser installs from, e.g.
+	// registry.StoreEdgeAddons.
+	Store string
+
+	// User data directories, relative to the per-OS application data
+	// directory: $XDG_CONFIG_HOME, ~/Library/Application Support and
+	// %LOCALAPPDATA%. They are empty for browsers whose profiles crx
+	// cannot read.
+	linuxUserData   string
+	macUserData     string
+	windowsUserData string
+}
+
+// Chrome is the name of Google Chrome, the default target.
+const Chrome = "chrome"
+
+// Firefox is the name of Mozilla Firefox.
+const Firefox = "firefox"
+
+// browsers are the supported browsers. Chrome channels share the policy
+// locations of Chrome on Linux and Windows.
+var browsers = []Browser{
+	{
+		Name:             Chrome,
+		DisplayName:      "Google Chrome",
+		LinuxPolicyDir:   "/etc/opt/chrome/policies/managed",
+		MacDomain:        "com.google.Chrome",
+		WindowsPolicyKey: `SOFTWARE\Policies\Google\Chrome`,
+		Store:            registry.StoreChromeWebStore,
+		linuxUserData:    "google-chrome",
+		macUserData:      "Google/Chrome",
+		windowsUserData:  `Google\Chrome\User Data`,
+	},
+	{
+		Name:             "chrome-beta",
+		DisplayName:      "Google Chrome Beta",
+		LinuxPolicyDir:   "/etc/opt/chrome/policies/managed",
+		MacDomain:        "com.google.Chrome.beta",
+		WindowsPolicyKey: `SOFTWARE\Policies\Google\Chrome`,
+		Store:            registry.StoreChromeWebStore,
+		linuxUserData:    "google-chrome-beta",
+		macUserData:      "Google/Chrome Beta",
+		windowsUserData:  `Google\Chrome Beta\User Data`,
+	},
+	{
+		Name:             "chrome-dev",
+		DisplayName:      "Google Chrome Dev",
+		LinuxPolicyDir:   "/etc/opt/chrome/policies/managed",
+		MacDomain:        "com.google.Chrome.dev",
+		WindowsPolicyKey: `SOFTWARE\Policies\Google\Chrome`,
+		Store:            registry.StoreChromeWebStore,
+		linuxUserData:    "google-chrome-unstable",
+		macUserData:      "Google/Chrome Dev",
+		windowsUserData:  `Google\Chrome Dev\User Data`,
+	},
+	{
+		Name:             "chrome-canary",
+		DisplayName:      "Google Chrome Canary",
+		LinuxPolicyDir:   "/etc/opt/chrome/policies/managed",
+		MacDomain:        "com.google.Chrome.canary",
+		WindowsPolicyKey: `SOFTWARE\Policies\Google\Chrome`,
+		Store:            registry.StoreChromeWebStore,
+		linuxUserData:    "google-chrome-canary",
+		macUserData:      "Google/Chrome Canary",
+		windowsUserData:  `Google\Chrome SxS\User Data`,
+	},
+	{
+		Name:             "chromium",
+		DisplayName:      "Chromium",
+		LinuxPolicyDir:   "/etc/chromium/policies/managed",
+		MacDomain:        "org.chromium.Chromium",
+		WindowsPolicyKey: `SOFTWARE\Policies\Chromium`,
+		Store:            registry.StoreChromeWebStore,
+		linuxUserData:    "chromium",
+		macUserData:      "Chromium",
+		windowsUserData:  `Chromium\User Data`,
+	},
+	{
+		Name:             "brave",
+		DisplayName:      "Brave",
+		LinuxPolicyDir:   "/etc/brave/policies/managed",
+		MacDomain:        "com.brave.Browser",
+		WindowsPolicyKey: `SOFTWARE\Policies\BraveSoftware\Brave`,
+		Store:            registry.StoreChromeWebStore,
+		linuxUserData:    "BraveSoftware/Brave-Browser",
+		macUserData:      "BraveSoftware/Brave-Browser",
+		windowsUserData:  `BraveSoftware\Brave-Browser\User Data`,
+	},
+	{
+		Name:             "edge",
+		DisplayName:      "Microsoft Edge",
+		LinuxPolicyDir:   "/etc/opt/edge/policies/managed",
+		MacDomain:        "com.microsoft.Edge",
+		WindowsPolicyKey: `SOFTWARE\Policies\Microsoft\Edge`,
+		Store:            registry.StoreEdgeAddons,
+		linuxUserData:    "microsoft-edge",
+		macUserData:      "Microsoft Edge",
+		windowsUserData:  `Microsoft\Edge\User Data`,
+	},
+	{
+		Name:             "vivaldi",
+		DisplayName:      "Vivaldi",
+		LinuxPolicyDir:   "/etc/opt/vivaldi/policies/managed",
+		MacDomain:        "com.vivaldi.Vivaldi",
+		WindowsPolicyKey: `SOFTWARE\Policies\Vivaldi`,
+		Store:            registry.StoreChromeWebStore,
+		linuxUserData:    "vivaldi",
+		macUserData:      "Vivaldi",
+		windowsUserData:  `Vivaldi\User Data`,
+	},
+	{
+		// Firefox reads policies.json from LinuxPolicyDir on Linux and from
+		// its installation directory elsewhere.
+		Name:           Firefox,
+		DisplayName:    "Mozilla Firefox",
+		LinuxPolicyDir: "/etc/firefox/policies",
+		Store:          registry.StoreFirefox,
+	},
+}
+
+// Lookup returns the browser with the given name.
+func Lookup(name string) (Browser, error) {
+	for _, b := range browsers {
+		if b.Name == name {
+			return b, nil
+		}
+	}
+	return Browser{}, fmt.Errorf("unsupported browser %q (supported: %v)", name, Names())
+}
+
+// Names returns the names of the supported browsers.
+func Names() []string {
+	names := make([]string, len(browsers))
+	for i, b := range browsers {
+		names[i] = b.Name
+	}
+	return names
+}
+
+// IsFirefox reports whether the browser is Firefox, which does not read
+// Chrome Enterprise policies.
+func (b Browser) IsFirefox() bool {
+	return b.Store == registry.StoreFirefox
+}
+
+// UserDataDir returns the user data directory of the browser for the
+// current user, or an empty string if crx cannot read its profiles.
+func (b Browser) UserDataDir() (string, error) {
+	if b.linuxUserData == "" {
+		return "", nil
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, "Library", "Application Support", filepath.FromSlash(b.macUserData)), nil
+	case "linux":
+		config, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(config, filepath.FromSlash(b.linuxUserData)), nil
+	case "windows":
+		return filepath.Join(os.Getenv("LOCALAPPDATA"), b.windowsUserData), nil
+	default:
+		return "", fmt.Errorf("unsupported OS: %s", runtime.GOOS)
+	}
+}
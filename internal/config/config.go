@@ -5,22 +5,80 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/sivchari/crx/internal/browser"
+	"github.com/sivchari/crx/internal/registry"
 )
 
 // Config represents the user configuration.
 type Config struct {
-	Extensions []string `yaml:"extensions"`
-	Settings   Settings `yaml:"settings"`
+	Registries []Registry  `yaml:"registries,omitempty"`
+	Extensions []Extension `yaml:"extensions"`
+	// Block lists registry names or extension IDs that may not be installed.
+	Block    []string `yaml:"block,omitempty"`
+	Settings Settings `yaml:"settings"`
+}
+
+// Registry represents a registry source. Registries are searched in the
+// order they are listed, so earlier entries take priority.
+type Registry struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"` // "github", "local"
+	Repo string `yaml:"repo,omitempty"`
+	Ref  string `yaml:"ref,omitempty"`
+	Path string `yaml:"path,omitempty"`
+	// TokenEnv names the environment variable holding a GitHub token for
+	// this registry. GITHUB_TOKEN and the gh CLI are used otherwise.
+	TokenEnv string `yaml:"token_env,omitempty"`
+	// PublicKeys are base64-encoded ed25519 keys trusted to sign the
+	// registry. When set, unsigned or tampered content is rejected.
+	PublicKeys []string `yaml:"public_keys,omitempty"`
 }
 
+// Registry type constants.
+const (
+	RegistryTypeGitHub = "github"
+	RegistryTypeLocal  = "local"
+)
+
 // Settings represents the application settings.
 type Settings struct {
 	PolicyPath string `yaml:"policy_path"`
 	Mode       string `yaml:"mode"` // "force_install", "normal_install", "allowed"
+	// InsecureUpdateHosts lists hosts allowed to serve extensions over
+	// plain HTTP. All other update URLs must use HTTPS.
+	InsecureUpdateHosts []string `yaml:"insecure_update_hosts,omitempty"`
+
+	// Lockdown blocks every extension that is not configured.
+	Lockdown bool `yaml:"lockdown,omitempty"`
+	// AllowedTypes restricts the types of apps and extensions users may
+	// install, e.g. "extension" or "theme".
+	AllowedTypes []string `yaml:"allowed_types,omitempty"`
+	// InstallSources are URL patterns users may install extensions from
+	// outside the Chrome Web Store.
+	InstallSources []string `yaml:"install_sources,omitempty"`
+	// BlockedInstallMessage is shown when users try to install an
+	// extension blocked by the block list or the lockdown.
+	BlockedInstallMessage string `yaml:"blocked_install_message,omitempty"`
+
+	// Browsers lists the browsers to write the policy for. Chrome is used
+	// when empty.
+	Browsers []string `yaml:"browsers,omitempty"`
+
+	// Backups is the number of previous policies kept for crx rollback.
+	// DefaultBackups is used when zero.
+	Backups int `yaml:"backups,omitempty"`
 }
 
+// DefaultBackups is the number of previous policies kept by default.
+const DefaultBackups = 10
+
+// extensionTypes are the allowed_types values accepted by Chrome.
+var extensionTypes = []string{"extension", "theme", "user_script", "hosted_app", "legacy_packaged_app", "platform_app"}
+
 // InstallMode constants.
 const (
 	ModeForceInstall  = "force_install"
@@ -28,10 +86,56 @@ const (
 	ModeAllowed       = "allowed"
 )
 
+// IsValidMode reports whether mode is a supported install mode.
+func IsValidMode(mode string) bool {
+	switch mode {
+	case ModeForceInstall, ModeNormalInstall, ModeAllowed:
+		return true
+	default:
+		return false
+	}
+}
+
+// ModeFor returns the install mode of ext: its own mode, or settings.mode.
+func (c *Config) ModeFor(ext Extension) string {
+	if ext.Mode != "" {
+		return ext.Mode
+	}
+	return c.Settings.Mode
+}
+
+// EffectiveBrowsers returns the browsers to write the policy for.
+// It falls back to Chrome when none are configured.
+func (c *Config) EffectiveBrowsers() ([]browser.Browser, error) {
+	names := c.Settings.Browsers
+	if len(names) == 0 {
+		names = []string{browser.Chrome}
+	}
+
+	browsers := make([]browser.Browser, 0, len(names))
+	for _, name := range names {
+		b, err := browser.Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		browsers = append(browsers, b)
+	}
+	return browsers, nil
+}
+
+// EffectiveBackups returns the number of previous policies to keep.
+func (c *Config) EffectiveBackups() int {
+	if c.Settings.Backups == 0 {
+		return DefaultBackups
+	}
+	return c.Settings.Backups
+}
+
 // DefaultConfig returns the default configuration.
 func DefaultConfig() *Config {
 	return &Config{
-		Extensions: []string{},
+		Registries: []Registry{DefaultRegistry()},
+		Extensions: []Extension{},
 		Settings: Settings{
 			PolicyPath: defaultPolicyPath(),
 			Mode:       ModeForceInstall,
@@ -39,6 +143,127 @@ func DefaultConfig() *Config {
 	}
 }
 
+// DefaultRegistry returns the public crx registry.
+func DefaultRegistry() Registry {
+	return Registry{
+		Name: registry.DefaultRegistryName,
+		Type: RegistryTypeGitHub,
+		Repo: registry.DefaultRegistryRepo,
+		Ref:  registry.DefaultRegistryRef,
+	}
+}
+
+// EffectiveRegistries returns the configured registries in priority order.
+// The default registry is used when none are configured.
+func (c *Config) EffectiveRegistries() []Registry {
+	if len(c.Registries) == 0 {
+		return []Registry{DefaultRegistry()}
+	}
+	return c.Registries
+}
+
+// Validate checks the configuration for errors.
+func (c *Config) Validate() error {
+	names := make(map[string]bool)
+	for i, r := range c.Registries {
+		if r.Name == "" {
+			return fmt.Errorf("registries[%d]: name is required", i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("registries[%d]: duplicate registry name %q", i, r.Name)
+		}
+		names[r.Name] = true
+
+		switch r.Type {
+		case RegistryTypeGitHub:
+			if r.Repo == "" {
+				return fmt.Errorf("registry %q: repo is required", r.Name)
+			}
+		case RegistryTypeLocal:
+			if r.Path == "" {
+				return fmt.Errorf("registry %q: path is required", r.Name)
+			}
+		default:
+			return fmt.Errorf("registry %q: unsupported type %q", r.Name, r.Type)
+		}
+
+		for _, key := range r.PublicKeys {
+			if _, err := registry.ParsePublicKey(key); err != nil {
+				return fmt.Errorf("registry %q: %w", r.Name, err)
+			}
+		}
+	}
+
+	if c.Settings.Mode != "" && !IsValidMode(c.Settings.Mode) {
+		return fmt.Errorf("settings.mode: unsupported mode %q", c.Settings.Mode)
+	}
+
+	for _, t := range c.Settings.AllowedTypes {
+		if !slices.Contains(extensionTypes, t) {
+			return fmt.Errorf("settings.allowed_types: unsupported type %q (supported: %s)", t, strings.Join(extensionTypes, ", "))
+		}
+	}
+	for _, source := range c.Settings.InstallSources {
+		if !strings.Contains(source, "://") {
+			return fmt.Errorf("settings.install_sources: %q is not a URL pattern like https://extensions.example.com/*", source)
+		}
+	}
+
+	if _, err := c.EffectiveBrowsers(); err != nil {
+		return fmt.Errorf("settings.browsers: %w", err)
+	}
+	for i, name := range c.Settings.Browsers {
+		if slices.Contains(c.Settings.Browsers[:i], name) {
+			return fmt.Errorf("settings.browsers: duplicate browser %q", name)
+		}
+	}
+
+	if c.Settings.Backups < 0 {
+		return fmt.Errorf("settings.backups: must not be negative, got %d", c.Settings.Backups)
+	}
+
+	for _, host := range c.Settings.InsecureUpdateHosts {
+		if host == "" || strings.ContainsAny(host, ":/") {
+			return fmt.Errorf("settings.insecure_update_hosts: %q must be a bare host name", host)
+		}
+	}
+
+	keys := make(map[string]bool)
+	ids := make(map[string]bool)
+	for i, ext := range c.Extensions {
+		if err := ext.validate(c.Settings.InsecureUpdateHosts); err != nil {
+			return fmt.Errorf("extensions[%d]: %w", i, err)
+		}
+		if keys[ext.Key()] {
+			return fmt.Errorf("extensions[%d]: duplicate extension %q", i, ext.Key())
+		}
+		keys[ext.Key()] = true
+		if ext.IsDirect() {
+			if ids[ext.ID] {
+				return fmt.Errorf("extensions[%d]: duplicate extension ID %s", i, ext.ID)
+			}
+			ids[ext.ID] = true
+		}
+	}
+
+	blocked := make(map[string]bool)
+	for i, entry := range c.Block {
+		if !IsExtensionID(entry) {
+			if err := registry.ValidateName(entry); err != nil {
+				return fmt.Errorf("block[%d]: must be a package name or an extension ID: %w", i, err)
+			}
+		}
+		if blocked[entry] {
+			return fmt.Errorf("block[%d]: duplicate entry %q", i, entry)
+		}
+		blocked[entry] = true
+		if keys[entry] || ids[entry] {
+			return fmt.Errorf("block[%d]: %s is also in extensions", i, entry)
+		}
+	}
+	return nil
+}
+
 func defaultPolicyPath() string {
 	switch os := getOS(); os {
 	case "darwin":
@@ -100,6 +325,10 @@ func LoadFrom(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
 	return &cfg, nil
 }
 
@@ -132,21 +361,78 @@ func (c *Config) SaveTo(path string) error {
 }
 
 // AddExtension adds an extension to the configuration.
-func (c *Config) AddExtension(name string) bool {
-	if slices.Contains(c.Extensions, name) {
+// It returns false if an entry with the same name or ID already exists.
+func (c *Config) AddExtension(ext Extension) bool {
+	if c.FindExtension(ext.Key()) != nil || (ext.IsDirect() && c.FindExtension(ext.ID) != nil) {
 		return false
 	}
-	c.Extensions = append(c.Extensions, name)
+	c.Extensions = append(c.Extensions, ext)
 	return true
 }
 
+// FindExtension returns the entry that name refers to by name or ID.
+func (c *Config) FindExtension(name string) *Extension {
+	for i := range c.Extensions {
+		if c.Extensions[i].Matches(name) {
+			return &c.Extensions[i]
+		}
+	}
+	return nil
+}
+
+// IsExtensionID reports whether s is an extension ID rather than a name.
+func IsExtensionID(s string) bool {
+	return registry.ValidateID(s) == nil
+}
+
+// BlockedNames returns the block entries that name registry packages.
+func (c *Config) BlockedNames() []string {
+	var names []string
+	for _, entry := range c.Block {
+		if !IsExtensionID(entry) {
+			names = append(names, entry)
+		}
+	}
+	return names
+}
+
+// ExtensionNames returns the key of every entry, in order.
+func (c *Config) ExtensionNames() []string {
+	names := make([]string, len(c.Extensions))
+	for i, ext := range c.Extensions {
+		names[i] = ext.Key()
+	}
+	return names
+}
+
 // RemoveExtension removes an extension from the configuration.
+// Direct entries can be removed by name or ID.
 func (c *Config) RemoveExtension(name string) bool {
 	for i, ext := range c.Extensions {
-		if ext == name {
+		if ext.Matches(name) {
 			c.Extensions = append(c.Extensions[:i], c.Extensions[i+1:]...)
 			return true
 		}
 	}
 	return false
 }
+
+// ReplaceExtension replaces an extension with the registry package newName,
+// keeping its position, mode and options. If the replacement is already
+// configured, the old extension is removed instead.
+func (c *Config) ReplaceExtension(oldName, newName string) bool {
+	i := slices.IndexFunc(c.Extensions, func(ext Extension) bool { return ext.Matches(oldName) })
+	if i < 0 {
+		return false
+	}
+	if c.FindExtension(newName) != nil {
+		c.Extensions = slices.Delete(c.Extensions, i, i+1)
+		return true
+	}
+	ext := &c.Extensions[i]
+	ext.Name = newName
+	// The replacement is resolved from the registry, not by a direct ID.
+	ext.ID = ""
+	ext.UpdateURL = ""
+	return true
+}
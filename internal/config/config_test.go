@@ -0,0 +1,79 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+const directID = "abcdefghijklmnopabcdefghijklmnop"
+
+func TestReplaceExtension(t *testing.T) {
+	pinned := ExtensionOptions{ToolbarPin: ToolbarForcePinned, BlockedPermissions: []string{"tabs"}}
+
+	tests := []struct {
+		name       string
+		extensions []Extension
+		oldName    string
+		want       []Extension
+		wantOK     bool
+	}{
+		{
+			name:       "plain name",
+			extensions: []Extension{{Name: "a"}, {Name: "old"}, {Name: "b"}},
+			oldName:    "old",
+			want:       []Extension{{Name: "a"}, {Name: "new"}, {Name: "b"}},
+			wantOK:     true,
+		},
+		{
+			name:       "mode and options kept",
+			extensions: []Extension{{Name: "old", Mode: ModeForceInstall, ExtensionOptions: pinned}},
+			oldName:    "old",
+			want:       []Extension{{Name: "new", Mode: ModeForceInstall, ExtensionOptions: pinned}},
+			wantOK:     true,
+		},
+		{
+			name: "direct entry by ID",
+			extensions: []Extension{{
+				ID:               directID,
+				UpdateURL:        "https://example.com/update.xml",
+				Mode:             ModeAllowed,
+				ExtensionOptions: pinned,
+			}},
+			oldName: directID,
+			want:    []Extension{{Name: "new", Mode: ModeAllowed, ExtensionOptions: pinned}},
+			wantOK:  true,
+		},
+		{
+			name:       "named direct entry",
+			extensions: []Extension{{Name: "old", ID: directID, Mode: ModeAllowed}},
+			oldName:    "old",
+			want:       []Extension{{Name: "new", Mode: ModeAllowed}},
+			wantOK:     true,
+		},
+		{
+			name:       "replacement already configured",
+			extensions: []Extension{{Name: "old", Mode: ModeAllowed}, {Name: "new"}},
+			oldName:    "old",
+			want:       []Extension{{Name: "new"}},
+			wantOK:     true,
+		},
+		{
+			name:       "not configured",
+			extensions: []Extension{{Name: "a"}},
+			oldName:    "old",
+			want:       []Extension{{Name: "a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Extensions: tt.extensions}
+			if ok := cfg.ReplaceExtension(tt.oldName, "new"); ok != tt.wantOK {
+				t.Errorf("ReplaceExtension() = %v, want %v", ok, tt.wantOK)
+			}
+			if !reflect.DeepEqual(cfg.Extensions, tt.want) {
+				t.Errorf("Extensions = %+v, want %+v", cfg.Extensions, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sivchari/crx/internal/registry"
+)
+
+// Extension is an entry of the extensions list. It is either the name of a
+// registry package, or a direct entry that specifies the extension ID
+// itself. Entries without options can be written as a plain name:
+//
+//	extensions:
+//	  - vimium
+//	  - name: grammarly
+//	    mode: allowed
+//	  - name: bitwarden
+//	    toolbar_pin: force_pinned
+//	  - id: abcdefghijklmnopabcdefghijklmnop
+//	    name: internal-tools
+//	    update_url: https://extensions.example.com/updates.xml
+type Extension struct {
+	Name      string `yaml:"name,omitempty"`
+	ID        string `yaml:"id,omitempty"`
+	UpdateURL string `yaml:"update_url,omitempty"`
+	// Mode overrides settings.mode for this extension.
+	Mode string `yaml:"mode,omitempty"`
+
+	ExtensionOptions `yaml:",inline"`
+}
+
+// ExtensionOptions are the ExtensionSettings policy options of an extension.
+// See https://chromeenterprise.google/policies/#ExtensionSettings.
+type ExtensionOptions struct {
+	// ToolbarPin is "force_pinned" or "default_unpinned".
+	ToolbarPin string `yaml:"toolbar_pin,omitempty"`
+	// BlockedPermissions are API permissions the extension may not use.
+	BlockedPermissions []string `yaml:"blocked_permissions,omitempty"`
+	// AllowedPermissions are exempted from BlockedPermissions.
+	AllowedPermissions []string `yaml:"allowed_permissions,omitempty"`
+	// RuntimeBlockedHosts are URL patterns the extension may not access.
+	RuntimeBlockedHosts []string `yaml:"runtime_blocked_hosts,omitempty"`
+	// RuntimeAllowedHosts are exempted from RuntimeBlockedHosts.
+	RuntimeAllowedHosts []string `yaml:"runtime_allowed_hosts,omitempty"`
+	// MinimumVersionRequired disables older versions of the extension.
+	MinimumVersionRequired string `yaml:"minimum_version_required,omitempty"`
+}
+
+// Toolbar pin states.
+const (
+	ToolbarForcePinned     = "force_pinned"
+	ToolbarDefaultUnpinned = "default_unpinned"
+)
+
+// maxRuntimeHosts is the number of host patterns Chrome accepts in each of
+// runtime_blocked_hosts and runtime_allowed_hosts.
+const maxRuntimeHosts = 100
+
+var versionPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+){0,3}$`)
+
+// IsZero reports whether no option is set.
+func (o ExtensionOptions) IsZero() bool {
+	return o.ToolbarPin == "" &&
+		len(o.BlockedPermissions) == 0 &&
+		len(o.AllowedPermissions) == 0 &&
+		len(o.RuntimeBlockedHosts) == 0 &&
+		len(o.RuntimeAllowedHosts) == 0 &&
+		o.MinimumVersionRequired == ""
+}
+
+// validate checks the option values.
+func (o ExtensionOptions) validate() error {
+	switch o.ToolbarPin {
+	case "", ToolbarForcePinned, ToolbarDefaultUnpinned:
+	default:
+		return fmt.Errorf("toolbar_pin must be %s or %s, got %q", ToolbarForcePinned, ToolbarDefaultUnpinned, o.ToolbarPin)
+	}
+
+	if slices.Contains(o.BlockedPermissions, "") || slices.Contains(o.AllowedPermissions, "") {
+		return fmt.Errorf("permissions must not be empty")
+	}
+
+	if err := validateHosts("runtime_blocked_hosts", o.RuntimeBlockedHosts); err != nil {
+		return err
+	}
+	if err := validateHosts("runtime_allowed_hosts", o.RuntimeAllowedHosts); err != nil {
+		return err
+	}
+
+	if o.MinimumVersionRequired != "" && !versionPattern.MatchString(o.MinimumVersionRequired) {
+		return fmt.Errorf("minimum_version_required %q is not a version like 1.2.3", o.MinimumVersionRequired)
+	}
+	return nil
+}
+
+// IsDirect reports whether the entry specifies an extension ID instead of
+// referring to a registry package.
+func (e Extension) IsDirect() bool {
+	return e.ID != ""
+}
+
+// Key returns the name that identifies the entry in the configuration and
+// in crx.lock: its name, or its ID for unnamed direct entries.
+func (e Extension) Key() string {
+	if e.Name != "" {
+		return e.Name
+	}
+	return e.ID
+}
+
+// Matches reports whether name refers to the entry by name or ID.
+func (e Extension) Matches(name string) bool {
+	return e.Key() == name || (e.IsDirect() && e.ID == name)
+}
+
+// Package returns the package definition of a direct entry.
+func (e Extension) Package() *registry.Package {
+	return &registry.Package{
+		Name:        e.Key(),
+		ID:          e.ID,
+		DisplayName: e.Key(),
+		UpdateURL:   e.UpdateURL,
+	}
+}
+
+// validateHosts checks the runtime host patterns of field.
+func validateHosts(field string, hosts []string) error {
+	if len(hosts) > maxRuntimeHosts {
+		return fmt.Errorf("%s accepts at most %d entries, got %d", field, maxRuntimeHosts, len(hosts))
+	}
+	for _, host := range hosts {
+		if host != "<all_urls>" && !strings.Contains(host, "://") {
+			return fmt.Errorf("%s: %q is not a URL pattern like *://*.example.com", field, host)
+		}
+	}
+	return nil
+}
+
+// validate checks the entry. insecureHosts are the hosts allowed to serve
+// update URLs over plain HTTP.
+func (e Extension) validate(insecureHosts []string) error {
+	if e.Mode != "" && !IsValidMode(e.Mode) {
+		return fmt.Errorf("%s: unsupported mode %q", e.Key(), e.Mode)
+	}
+	if err := e.ExtensionOptions.validate(); err != nil {
+		return fmt.Errorf("%s: %w", e.Key(), err)
+	}
+
+	if !e.IsDirect() {
+		if e.Name == "" {
+			return fmt.Errorf("name or id is required")
+		}
+		if e.UpdateURL != "" {
+			return fmt.Errorf("%s: update_url requires id", e.Name)
+		}
+		return nil
+	}
+
+	if err := registry.ValidateID(e.ID); err != nil {
+		return err
+	}
+	if e.Name != "" {
+		if err := registry.ValidateName(e.Name); err != nil {
+			return err
+		}
+	}
+	if e.UpdateURL != "" {
+		if err := registry.ValidateUpdateURL(e.UpdateURL, insecureHosts); err != nil {
+			return fmt.Errorf("%s: %w", e.Key(), err)
+		}
+	}
+	return nil
+}
+
+// extensionFields avoids recursion when decoding and encoding Extension.
+type extensionFields Extension
+
+// UnmarshalYAML decodes either a package name or a direct entry.
+func (e *Extension) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*e = Extension{Name: node.Value}
+		return nil
+	case yaml.MappingNode:
+		// Configured extensions are never blocked, so the message is only
+		// meaningful for the block list and the lockdown.
+		for i := 0; i < len(node.Content); i += 2 {
+			if key := node.Content[i]; key.Value == "blocked_install_message" {
+				return fmt.Errorf("line %d: blocked_install_message is set in settings, not per extension", key.Line)
+			}
+		}
+		var fields extensionFields
+		if err := node.Decode(&fields); err != nil {
+			return err
+		}
+		*e = Extension(fields)
+		return nil
+	default:
+		return fmt.Errorf("line %d: extension must be a name or a mapping", node.Line)
+	}
+}
+
+// MarshalYAML encodes entries without options as plain names.
+func (e Extension) MarshalYAML() (any, error) {
+	if e.ID == "" && e.UpdateURL == "" && e.Mode == "" && e.ExtensionOptions.IsZero() {
+		return e.Name, nil
+	}
+	return extensionFields(e), nil
+}
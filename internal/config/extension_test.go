@@ -0,0 +1,123 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExtensionUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Extension
+		wantErr string
+	}{
+		{
+			name:  "plain names",
+			input: "- vimium\n- ublock-origin-lite\n",
+			want:  []Extension{{Name: "vimium"}, {Name: "ublock-origin-lite"}},
+		},
+		{
+			name:  "name mapping",
+			input: "- name: vimium\n",
+			want:  []Extension{{Name: "vimium"}},
+		},
+		{
+			name:  "direct entry",
+			input: "- id: " + directID + "\n  name: internal-tools\n  update_url: https://example.com/update.xml\n",
+			want:  []Extension{{ID: directID, Name: "internal-tools", UpdateURL: "https://example.com/update.xml"}},
+		},
+		{
+			name:  "mode and options",
+			input: "- vimium\n- name: bitwarden\n  mode: force_install\n  toolbar_pin: force_pinned\n  blocked_permissions: [tabs]\n",
+			want: []Extension{
+				{Name: "vimium"},
+				{Name: "bitwarden", Mode: ModeForceInstall, ExtensionOptions: ExtensionOptions{
+					ToolbarPin:         ToolbarForcePinned,
+					BlockedPermissions: []string{"tabs"},
+				}},
+			},
+		},
+		{
+			name:    "blocked_install_message",
+			input:   "- vimium\n- name: bitwarden\n  blocked_install_message: no\n",
+			wantErr: "line 3: blocked_install_message is set in settings",
+		},
+		{
+			name:    "sequence",
+			input:   "- [vimium]\n",
+			wantErr: "line 1: extension must be a name or a mapping",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Extension
+			err := yaml.Unmarshal([]byte(tt.input), &got)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtensionMarshalYAML(t *testing.T) {
+	tests := []struct {
+		name       string
+		extensions []Extension
+		want       string
+	}{
+		{
+			name:       "plain name",
+			extensions: []Extension{{Name: "vimium"}},
+			want:       "- vimium\n",
+		},
+		{
+			name:       "direct entry",
+			extensions: []Extension{{ID: directID, Name: "internal-tools"}},
+			want:       "- name: internal-tools\n  id: " + directID + "\n",
+		},
+		{
+			name:       "mode",
+			extensions: []Extension{{Name: "vimium", Mode: ModeAllowed}},
+			want:       "- name: vimium\n  mode: allowed\n",
+		},
+		{
+			name:       "options",
+			extensions: []Extension{{Name: "vimium", ExtensionOptions: ExtensionOptions{ToolbarPin: ToolbarDefaultUnpinned}}},
+			want:       "- name: vimium\n  toolbar_pin: default_unpinned\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := yaml.Marshal(tt.extensions)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("got %q, want %q", data, tt.want)
+			}
+
+			var got []Extension
+			if err := yaml.Unmarshal(data, &got); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.extensions) {
+				t.Errorf("round trip = %+v, want %+v", got, tt.extensions)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	tests := []struct {
+		name string
+		// existing is the content of the file before, if any.
+		existing *string
+		data     string
+		perm     os.FileMode
+	}{
+		{name: "new file", data: "new", perm: 0644},
+		{name: "replaces the file", existing: ptr("old content"), data: "new", perm: 0644},
+		{name: "sets the permissions", existing: ptr("old"), data: "new", perm: 0600},
+		{name: "empty", existing: ptr("old"), data: "", perm: 0644},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "policy.json")
+			if tt.existing != nil {
+				if err := os.WriteFile(path, []byte(*tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := WriteFileAtomic(path, []byte(tt.data), tt.perm); err != nil {
+				t.Fatalf("WriteFileAtomic() error = %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(data); got != tt.data {
+				t.Errorf("content = %q, want %q", got, tt.data)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Mode().Perm(); got != tt.perm {
+				t.Errorf("permissions = %v, want %v", got, tt.perm)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("directory has %d entries, want only the file", len(entries))
+			}
+		})
+	}
+
+	t.Run("missing directory", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing", "policy.json")
+		if err := WriteFileAtomic(path, []byte("new"), 0644); err == nil {
+			t.Error("WriteFileAtomic() error = nil, want an error")
+		}
+	})
+}
+
+func ptr(s string) *string {
+	return &s
+}
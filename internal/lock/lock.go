@@ -0,0 +1,189 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sivchari/crx/internal/config"
+	"github.com/sivchari/crx/internal/registry"
+)
+
+// FileName is the name of the lockfile, stored next to config.yaml.
+const FileName = "crx.lock"
+
+// Version is the current lockfile format version.
+const Version = 1
+
+// Lockfile pins the resolved packages of the configured extensions.
+type Lockfile struct {
+	Version    int     `yaml:"version"`
+	Extensions []Entry `yaml:"extensions"`
+	// Blocked pins the registry packages named in the block list.
+	Blocked []Entry `yaml:"blocked,omitempty"`
+}
+
+// Entry represents a single locked extension.
+type Entry struct {
+	Name     string `yaml:"name"`
+	ID       string `yaml:"id"`
+	Registry string `yaml:"registry,omitempty"` // empty for direct entries
+	Ref      string `yaml:"ref,omitempty"`
+	Commit   string `yaml:"commit,omitempty"`
+	Digest   string `yaml:"digest,omitempty"`
+	// Package is the full package definition used by frozen applies.
+	Package registry.Package `yaml:"package"`
+}
+
+// Path returns the lockfile path.
+func Path() (string, error) {
+	dir, err := config.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// Load loads the lockfile from the default path.
+func Load() (*Lockfile, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	return LoadFrom(path)
+}
+
+// LoadFrom loads the lockfile from the specified path.
+func LoadFrom(path string) (*Lockfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile: %w", err)
+	}
+
+	var lf Lockfile
+	if err := yaml.Unmarshal(data, &lf); err != nil {
+		return nil, fmt.Errorf("failed to parse lockfile: %w", err)
+	}
+	if lf.Version != Version {
+		return nil, fmt.Errorf("unsupported lockfile version: %d", lf.Version)
+	}
+
+	return &lf, nil
+}
+
+// Save saves the lockfile to the default path.
+func (l *Lockfile) Save() error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	return l.SaveTo(path)
+}
+
+// SaveTo saves the lockfile to the specified path.
+func (l *Lockfile) SaveTo(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create lockfile directory: %w", err)
+	}
+
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lockfile: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write lockfile: %w", err)
+	}
+
+	return nil
+}
+
+// Find returns the entry for the named extension.
+func (l *Lockfile) Find(name string) (*Entry, bool) {
+	for i := range l.Extensions {
+		if l.Extensions[i].Name == name {
+			return &l.Extensions[i], true
+		}
+	}
+	return nil, false
+}
+
+// Check reports an error if the locked extensions differ from the
+// configured ones. Direct entries must also match the locked ID and update
+// URL.
+func (l *Lockfile) Check(exts []config.Extension) error {
+	var missing, changed, extra []string
+	for _, ext := range exts {
+		e, ok := l.Find(ext.Key())
+		switch {
+		case !ok:
+			missing = append(missing, ext.Key())
+		case ext.IsDirect() != (e.Registry == ""):
+			changed = append(changed, ext.Key())
+		case ext.IsDirect() && (e.ID != ext.ID || e.Package.UpdateURL != ext.UpdateURL):
+			changed = append(changed, ext.Key())
+		}
+	}
+	for _, e := range l.Extensions {
+		if !slices.ContainsFunc(exts, func(ext config.Extension) bool { return ext.Key() == e.Name }) {
+			extra = append(extra, e.Name)
+		}
+	}
+
+	if len(missing) == 0 && len(changed) == 0 && len(extra) == 0 {
+		return nil
+	}
+
+	var details []string
+	if len(missing) > 0 {
+		details = append(details, "not locked: "+strings.Join(missing, ", "))
+	}
+	if len(changed) > 0 {
+		details = append(details, "changed: "+strings.Join(changed, ", "))
+	}
+	if len(extra) > 0 {
+		details = append(details, "not configured: "+strings.Join(extra, ", "))
+	}
+	return fmt.Errorf("config and %s disagree (%s)", FileName, strings.Join(details, "; "))
+}
+
+// Packages returns the locked packages for the configured extensions, in
+// the order given.
+func (l *Lockfile) Packages(exts []config.Extension) ([]*registry.Package, error) {
+	if err := l.Check(exts); err != nil {
+		return nil, err
+	}
+
+	packages := make([]*registry.Package, 0, len(exts))
+	for _, ext := range exts {
+		e, _ := l.Find(ext.Key())
+		packages = append(packages, e.pkg())
+	}
+	return packages, nil
+}
+
+// BlockedPackages returns the locked packages for the blocked names, in the
+// order given.
+func (l *Lockfile) BlockedPackages(names []string) ([]*registry.Package, error) {
+	packages := make([]*registry.Package, 0, len(names))
+	for _, name := range names {
+		i := slices.IndexFunc(l.Blocked, func(e Entry) bool { return e.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("blocked extension %s is not locked in %s", name, FileName)
+		}
+		packages = append(packages, l.Blocked[i].pkg())
+	}
+	return packages, nil
+}
+
+// pkg returns the locked package with its runtime fields restored.
+func (e *Entry) pkg() *registry.Package {
+	pkg := e.Package
+	pkg.Registry = e.Registry
+	pkg.Digest = e.Digest
+	return &pkg
+}
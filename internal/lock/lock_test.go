@@ -0,0 +1,117 @@
+package lock
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sivchari/crx/internal/config"
+	"github.com/sivchari/crx/internal/registry"
+)
+
+const (
+	vimiumID = "dbepggeogbaibhgnhhndojpepiihcmeb"
+	directID = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+)
+
+func TestCheck(t *testing.T) {
+	lf := &Lockfile{
+		Version: Version,
+		Extensions: []Entry{
+			{Name: "vimium", ID: vimiumID, Registry: "official", Package: registry.Package{Name: "vimium", ID: vimiumID}},
+			{Name: "internal", ID: directID, Package: registry.Package{Name: "internal", ID: directID, UpdateURL: "https://example.com/update.xml"}},
+		},
+	}
+
+	tests := []struct {
+		name string
+		exts []config.Extension
+		// wantErr is a part of the expected error, or empty for none.
+		wantErr string
+	}{
+		{
+			name: "matching",
+			exts: []config.Extension{
+				{Name: "vimium"},
+				{Name: "internal", ID: directID, UpdateURL: "https://example.com/update.xml"},
+			},
+		},
+		{
+			name: "matching in another order",
+			exts: []config.Extension{
+				{Name: "internal", ID: directID, UpdateURL: "https://example.com/update.xml"},
+				{Name: "vimium"},
+			},
+		},
+		{
+			name: "not locked",
+			exts: []config.Extension{
+				{Name: "vimium"},
+				{Name: "internal", ID: directID, UpdateURL: "https://example.com/update.xml"},
+				{Name: "dark-reader"},
+			},
+			wantErr: "not locked: dark-reader",
+		},
+		{
+			name: "not configured",
+			exts: []config.Extension{
+				{Name: "vimium"},
+			},
+			wantErr: "not configured: internal",
+		},
+		{
+			name: "registry package made direct",
+			exts: []config.Extension{
+				{Name: "vimium", ID: vimiumID},
+				{Name: "internal", ID: directID, UpdateURL: "https://example.com/update.xml"},
+			},
+			wantErr: "changed: vimium",
+		},
+		{
+			name: "direct entry made a registry package",
+			exts: []config.Extension{
+				{Name: "vimium"},
+				{Name: "internal"},
+			},
+			wantErr: "changed: internal",
+		},
+		{
+			name: "direct ID changed",
+			exts: []config.Extension{
+				{Name: "vimium"},
+				{Name: "internal", ID: "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", UpdateURL: "https://example.com/update.xml"},
+			},
+			wantErr: "changed: internal",
+		},
+		{
+			name: "direct update URL changed",
+			exts: []config.Extension{
+				{Name: "vimium"},
+				{Name: "internal", ID: directID, UpdateURL: "https://example.com/v2/update.xml"},
+			},
+			wantErr: "changed: internal",
+		},
+		{
+			name: "several differences",
+			exts: []config.Extension{
+				{Name: "internal", ID: directID},
+				{Name: "dark-reader"},
+			},
+			wantErr: "not locked: dark-reader; changed: internal; not configured: vimium",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := lf.Check(tt.exts)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Check() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Check() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,461 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/sivchari/crx/internal/browser"
+	"github.com/sivchari/crx/internal/fsutil"
+)
+
+// backupFileName is the name of the metadata file in a backup directory.
+// It is written last, so directories without it are incomplete.
+const backupFileName = "backup.json"
+
+// backupIDFormat formats backup IDs, which sort by creation time.
+const backupIDFormat = "20060102T150405.000000000Z"
+
+// Kinds of targets.
+const (
+	// targetFile is a policy file written by crx.
+	targetFile = "file"
+	// targetFirefox is the policies.json of Firefox, of which crx only
+	// owns the ExtensionSettings policy.
+	targetFirefox = "firefox"
+	// targetMobileconfig is the macOS configuration profile, which the
+	// user installs after it is written.
+	targetMobileconfig = "mobileconfig"
+	// targetRegistry is a Windows policy key.
+	targetRegistry = "registry"
+)
+
+// target is a place crx writes a policy to.
+type target struct {
+	Kind     string `json:"kind"`
+	Location string `json:"location"`
+}
+
+// Backup is a copy of the policies crx manages, saved before they were
+// replaced.
+type Backup struct {
+	// ID identifies the backup; it is the name of its directory.
+	ID        string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	// Command is the crx command that replaced the policies.
+	Command string        `json:"command"`
+	Entries []backupEntry `json:"entries"`
+
+	dir string
+}
+
+// backupEntry is the saved policy of a single target.
+type backupEntry struct {
+	target
+	// File is the name of the saved copy in the backup directory. It is
+	// empty if the target had no policy.
+	File string `json:"file,omitempty"`
+	// Owned are the keys of the ExtensionSettings entries crx had written
+	// to a Firefox policies.json.
+	Owned []string `json:"owned,omitempty"`
+}
+
+// BackupDir returns the directory backups are kept in.
+func BackupDir() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "backups"), nil
+}
+
+// Backups returns the saved backups, newest first.
+func Backups() ([]*Backup, error) {
+	dir, err := BackupDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	var backups []*Backup
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		b, err := loadBackup(filepath.Join(dir, e.Name()))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, b)
+	}
+	slices.Reverse(backups)
+	return backups, nil
+}
+
+// loadBackup loads the backup stored in dir.
+func loadBackup(dir string) (*Backup, error) {
+	data, err := os.ReadFile(filepath.Join(dir, backupFileName))
+	if err != nil {
+		return nil, err
+	}
+	var b Backup
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse backup %s: %w", filepath.Base(dir), err)
+	}
+	b.ID = filepath.Base(dir)
+	b.dir = dir
+	return &b, nil
+}
+
+// Locations returns the locations of the policies in the backup.
+func (b *Backup) Locations() []string {
+	locations := make([]string, len(b.Entries))
+	for i, e := range b.Entries {
+		locations[i] = e.Location
+	}
+	return locations
+}
+
+// targets returns the targets of the browsers on the current OS.
+func targets(browsers []browser.Browser) ([]target, error) {
+	var ts []target
+	for _, b := range browsers {
+		t, err := targetOf(b)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(ts, t) {
+			ts = append(ts, t)
+		}
+	}
+	return ts, nil
+}
+
+// targetOf returns the target of the browser on the current OS.
+func targetOf(b browser.Browser) (target, error) {
+	if b.IsFirefox() {
+		return target{Kind: targetFirefox, Location: firefoxPoliciesPath(b)}, nil
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		path, err := mobileconfigPath()
+		if err != nil {
+			return target{}, err
+		}
+		return target{Kind: targetMobileconfig, Location: path}, nil
+	case "linux":
+		return target{Kind: targetFile, Location: filepath.Join(b.LinuxPolicyDir, policyFileName)}, nil
+	case "windows":
+		return target{Kind: targetRegistry, Location: b.WindowsPolicyKey}, nil
+	default:
+		return target{}, fmt.Errorf("unsupported OS: %s", runtime.GOOS)
+	}
+}
+
+// read returns the current policy of the target, or nil if there is none.
+func (t target) read() ([]byte, error) {
+	if t.Kind == targetRegistry {
+		return readWindowsSnapshot(t.Location)
+	}
+	data, err := os.ReadFile(t.Location)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// restore replaces the policy of the target with data, removing it if data
+// is nil.
+func (t target) restore(data []byte) error {
+	if t.Kind == targetRegistry {
+		return restoreWindows(t.Location, data)
+	}
+	if data == nil {
+		if err := os.Remove(t.Location); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %w", t.Location, err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(t.Location), 0755); err != nil {
+		return fmt.Errorf("failed to create policy directory: %w", err)
+	}
+	return fsutil.WriteFileAtomic(t.Location, data, 0644)
+}
+
+// restore restores the saved policy of the entry, data.
+func (e backupEntry) restore(data []byte) error {
+	if e.Kind == targetFirefox {
+		return restoreFirefox(e.Location, data, e.Owned)
+	}
+	return e.target.restore(data)
+}
+
+// saveBackup saves the current policies of the targets as a new backup.
+func saveBackup(ts []target, command string) (*Backup, error) {
+	b, err := newBackup(command)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.save(ts); err != nil {
+		_ = b.remove()
+		return nil, err
+	}
+	return b, nil
+}
+
+// newBackup creates the directory of a new backup.
+func newBackup(command string) (*Backup, error) {
+	root, err := BackupDir()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	b := &Backup{
+		ID:        now.Format(backupIDFormat),
+		CreatedAt: now,
+		Command:   command,
+		dir:       filepath.Join(root, now.Format(backupIDFormat)),
+	}
+	if err := os.MkdirAll(b.dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	return b, nil
+}
+
+// save saves the current policies of the targets into the backup.
+func (b *Backup) save(ts []target) error {
+	for i, t := range ts {
+		data, err := t.read()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", t.Location, err)
+		}
+		entry := backupEntry{target: t}
+		if t.Kind == targetFirefox {
+			if entry.Owned, err = loadFirefoxOwned(t.Location); err != nil {
+				return err
+			}
+		}
+		if data != nil {
+			entry.File = strconv.Itoa(i)
+			if err := os.WriteFile(filepath.Join(b.dir, entry.File), data, 0600); err != nil {
+				return fmt.Errorf("failed to write backup: %w", err)
+			}
+		}
+		b.Entries = append(b.Entries, entry)
+	}
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal backup: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(filepath.Join(b.dir, backupFileName), data, 0600); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return nil
+}
+
+// data returns the saved policy of the entry, or nil if there was none.
+func (b *Backup) data(e backupEntry) ([]byte, error) {
+	if e.File == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(b.dir, e.File))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+	return data, nil
+}
+
+// restore restores the saved policies. The configuration profile is only
+// written, not installed.
+func (b *Backup) restore() error {
+	for _, e := range b.Entries {
+		data, err := b.data(e)
+		if err != nil {
+			return err
+		}
+		if err := e.restore(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// current reports whether the targets still have the policies saved in b.
+func (b *Backup) current() (bool, error) {
+	for _, e := range b.Entries {
+		saved, err := b.data(e)
+		if err != nil {
+			return false, err
+		}
+		data, err := e.read()
+		if err != nil {
+			return false, fmt.Errorf("failed to read %s: %w", e.Location, err)
+		}
+		if !bytes.Equal(saved, data) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// same reports whether b and other saved the same policies of the same
+// targets.
+func (b *Backup) same(other *Backup) (bool, error) {
+	if len(b.Entries) != len(other.Entries) {
+		return false, nil
+	}
+	for i, e := range b.Entries {
+		if e.target != other.Entries[i].target || !slices.Equal(e.Owned, other.Entries[i].Owned) {
+			return false, nil
+		}
+		data, err := b.data(e)
+		if err != nil {
+			return false, err
+		}
+		otherData, err := other.data(other.Entries[i])
+		if err != nil {
+			return false, err
+		}
+		if !bytes.Equal(data, otherData) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// applyWithBackup backs up the policies of the targets, runs apply and
+// restores them if it fails. The backup is discarded if a rollback does
+// not need it: when apply left the policies unchanged, or when the newest
+// backup already saved the same policies. Otherwise scheduled applies of
+// an unchanged config would prune the backups of earlier policies.
+func applyWithBackup(ts []target, command string, apply func() error) error {
+	backups, err := Backups()
+	if err != nil {
+		return err
+	}
+	backup, err := saveBackup(ts, command)
+	if err != nil {
+		return fmt.Errorf("failed to back up the current policy: %w", err)
+	}
+	if err := apply(); err != nil {
+		if rerr := backup.restore(); rerr != nil {
+			return fmt.Errorf("%w; restoring the previous policy failed: %v", err, rerr)
+		}
+		_ = backup.remove()
+		return err
+	}
+
+	redundant, err := backup.current()
+	if err != nil {
+		return err
+	}
+	if !redundant && len(backups) > 0 {
+		if redundant, err = backup.same(backups[0]); err != nil {
+			return err
+		}
+	}
+	if redundant {
+		if err := backup.remove(); err != nil {
+			return fmt.Errorf("failed to remove backup %s: %w", backup.ID, err)
+		}
+	}
+	return nil
+}
+
+// remove deletes the backup.
+func (b *Backup) remove() error {
+	return os.RemoveAll(b.dir)
+}
+
+// pruneBackups deletes all but the newest keep backups.
+func pruneBackups(keep int) error {
+	backups, err := Backups()
+	if err != nil {
+		return err
+	}
+	for _, b := range backups[min(keep, len(backups)):] {
+		if err := b.remove(); err != nil {
+			return fmt.Errorf("failed to remove backup %s: %w", b.ID, err)
+		}
+	}
+	return nil
+}
+
+// Rollback restores the policies saved in b, keeping the newest keep
+// backups. The policies it replaces are backed up first, so a rollback
+// can be rolled back as well.
+func Rollback(b *Backup, keep int) error {
+	ts := make([]target, len(b.Entries))
+	for i, e := range b.Entries {
+		ts[i] = e.target
+	}
+	current, err := saveBackup(ts, "rollback")
+	if err != nil {
+		return fmt.Errorf("failed to back up the current policy: %w", err)
+	}
+
+	if err := b.restore(); err != nil {
+		if rerr := current.restore(); rerr != nil {
+			return fmt.Errorf("%w; restoring the replaced policy failed: %v", err, rerr)
+		}
+		_ = current.remove()
+		return err
+	}
+
+	if err := recordRestored(b); err != nil {
+		return err
+	}
+
+	// The restored configuration profile takes effect once installed. An
+	// installed profile is left alone if none was saved, since the saved
+	// file may just have been deleted.
+	for _, e := range b.Entries {
+		if e.Kind == targetMobileconfig && e.File != "" {
+			if err := openProfile(e.Location); err != nil {
+				return err
+			}
+		}
+	}
+
+	return pruneBackups(keep)
+}
+
+// recordRestored adds the browsers whose policy b restored to the browsers
+// of earlier applies, so that a later apply removes the restored policy if
+// they are no longer configured.
+func recordRestored(b *Backup) error {
+	names, err := loadApplied()
+	if err != nil {
+		return err
+	}
+	for _, name := range browser.Names() {
+		br, _ := browser.Lookup(name)
+		t, err := targetOf(br)
+		if err != nil {
+			return err
+		}
+		restored := slices.ContainsFunc(b.Entries, func(e backupEntry) bool { return e.target == t && e.File != "" })
+		if restored && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return recordApplied(names)
+}
@@ -0,0 +1,407 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBackupRestore(t *testing.T) {
+	const (
+		firefoxSaved = `{
+  "policies": {
+    "DisableTelemetry": true,
+    "ExtensionSettings": {"*": {"installation_mode": "blocked"}}
+  }
+}
+`
+		firefoxApplied = `{"policies": {"DisableTelemetry": true, "ExtensionSettings": {"addon@example.org": {"installation_mode": "allowed"}}}}`
+		firefoxAdmin   = `{
+  "policies": {
+    "ExtensionSettings": {"admin@example.org": {"installation_mode": "force_installed"}}
+  }
+}
+`
+	)
+
+	tests := []struct {
+		name   string
+		kind   string
+		before *string
+		// after is the content written after the backup was saved, or nil
+		// to remove the file.
+		after *string
+		// ownedBefore and ownedAfter are the ExtensionSettings entries crx
+		// wrote to a Firefox file before and after.
+		ownedBefore []string
+		ownedAfter  []string
+		// want is the content after the restore, or nil if the file must
+		// not exist. Only Firefox files that are not restored verbatim may
+		// differ in formatting.
+		want     *string
+		verbatim bool
+	}{
+		{
+			name:     "file replaced",
+			kind:     targetFile,
+			before:   ptr(`{"ExtensionInstallAllowlist":["a"]}`),
+			after:    ptr(`{"ExtensionInstallAllowlist":["b"]}`),
+			want:     ptr(`{"ExtensionInstallAllowlist":["a"]}`),
+			verbatim: true,
+		},
+		{
+			name:   "file created",
+			kind:   targetFile,
+			before: nil,
+			after:  ptr(`{"ExtensionInstallAllowlist":["b"]}`),
+			want:   nil,
+		},
+		{
+			name:     "file removed",
+			kind:     targetFile,
+			before:   ptr(`{"ExtensionInstallAllowlist":["a"]}`),
+			after:    nil,
+			want:     ptr(`{"ExtensionInstallAllowlist":["a"]}`),
+			verbatim: true,
+		},
+		{
+			name:        "Firefox restored verbatim",
+			kind:        targetFirefox,
+			before:      ptr(firefoxSaved),
+			ownedBefore: []string{"*"},
+			after:       ptr(firefoxApplied),
+			ownedAfter:  []string{"addon@example.org"},
+			want:        ptr(firefoxSaved),
+			verbatim:    true,
+		},
+		{
+			name:        "Firefox keeps policies changed since",
+			kind:        targetFirefox,
+			before:      ptr(firefoxSaved),
+			ownedBefore: []string{"*"},
+			after:       ptr(`{"policies": {"DisableTelemetry": false}}`),
+			want: ptr(`{
+  "policies": {
+    "DisableTelemetry": false,
+    "ExtensionSettings": {"*": {"installation_mode": "blocked"}}
+  }
+}
+`),
+		},
+		{
+			name:       "Firefox keeps entries crx did not write",
+			kind:       targetFirefox,
+			before:     ptr(firefoxAdmin),
+			after:      ptr(`{"policies": {"ExtensionSettings": {"admin@example.org": {"installation_mode": "force_installed"}, "addon@example.org": {"installation_mode": "allowed"}}}}`),
+			ownedAfter: []string{"addon@example.org"},
+			want:       ptr(firefoxAdmin),
+			verbatim:   true,
+		},
+		{
+			name:        "Firefox keeps entries added since",
+			kind:        targetFirefox,
+			before:      ptr(firefoxSaved),
+			ownedBefore: []string{"*"},
+			after:       ptr(`{"policies": {"DisableTelemetry": true, "ExtensionSettings": {"admin@example.org": {"installation_mode": "force_installed"}, "addon@example.org": {"installation_mode": "allowed"}}}}`),
+			ownedAfter:  []string{"addon@example.org"},
+			want:        ptr(`{"policies": {"DisableTelemetry": true, "ExtensionSettings": {"*": {"installation_mode": "blocked"}, "admin@example.org": {"installation_mode": "force_installed"}}}}`),
+		},
+		{
+			name:       "Firefox file created by crx",
+			kind:       targetFirefox,
+			before:     nil,
+			after:      ptr(`{"policies": {"ExtensionSettings": {"addon@example.org": {"installation_mode": "allowed"}}}}`),
+			ownedAfter: []string{"addon@example.org"},
+			want:       nil,
+		},
+		{
+			name:       "Firefox policies added since are kept",
+			kind:       targetFirefox,
+			before:     nil,
+			after:      ptr(`{"policies": {"DisableTelemetry": true, "ExtensionSettings": {"addon@example.org": {"installation_mode": "allowed"}}}}`),
+			ownedAfter: []string{"addon@example.org"},
+			want: ptr(`{
+  "policies": {
+    "DisableTelemetry": true
+  }
+}
+`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_STATE_HOME", t.TempDir())
+			path := filepath.Join(t.TempDir(), "policies", "policy.json")
+			setTestFile(t, path, tt.before)
+			setOwned(t, path, tt.ownedBefore)
+
+			b, err := saveBackup([]target{{Kind: tt.kind, Location: path}}, "apply")
+			if err != nil {
+				t.Fatalf("saveBackup() error = %v", err)
+			}
+			setTestFile(t, path, tt.after)
+			setOwned(t, path, tt.ownedAfter)
+
+			backups, err := Backups()
+			if err != nil {
+				t.Fatalf("Backups() error = %v", err)
+			}
+			if len(backups) != 1 || backups[0].ID != b.ID || backups[0].Command != "apply" {
+				t.Fatalf("Backups() = %+v, want the saved backup", backups)
+			}
+			if err := backups[0].restore(); err != nil {
+				t.Fatalf("restore() error = %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			switch {
+			case tt.want == nil && !os.IsNotExist(err):
+				t.Errorf("file exists after restore (error %v), want it removed", err)
+			case tt.want != nil && err != nil:
+				t.Errorf("failed to read the restored file: %v", err)
+			case tt.want != nil && tt.verbatim && string(data) != *tt.want:
+				t.Errorf("content = %s, want %s", data, *tt.want)
+			case tt.want != nil && !sameJSON(t, string(data), *tt.want):
+				t.Errorf("content = %s, want %s", data, *tt.want)
+			}
+			if tt.kind != targetFirefox {
+				return
+			}
+			owned, err := loadFirefoxOwned(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(owned, tt.ownedBefore) {
+				t.Errorf("owned entries = %v, want %v", owned, tt.ownedBefore)
+			}
+		})
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	tests := []struct {
+		name  string
+		saved int
+		keep  int
+		want  int
+	}{
+		{name: "none to prune", saved: 2, keep: 3, want: 2},
+		{name: "prunes the oldest", saved: 4, keep: 2, want: 2},
+		{name: "keeps none", saved: 2, keep: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_STATE_HOME", t.TempDir())
+			path := filepath.Join(t.TempDir(), "policy.json")
+
+			var ids []string
+			for i := range tt.saved {
+				writeTestFile(t, path, strings.Repeat("x", i))
+				b, err := saveBackup([]target{{Kind: targetFile, Location: path}}, "apply")
+				if err != nil {
+					t.Fatalf("saveBackup() error = %v", err)
+				}
+				ids = append(ids, b.ID)
+			}
+			// An incomplete backup is not listed, and not pruned either.
+			root, err := BackupDir()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.MkdirAll(filepath.Join(root, "incomplete"), 0700); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := pruneBackups(tt.keep); err != nil {
+				t.Fatalf("pruneBackups() error = %v", err)
+			}
+
+			backups, err := Backups()
+			if err != nil {
+				t.Fatalf("Backups() error = %v", err)
+			}
+			if len(backups) != tt.want {
+				t.Fatalf("got %d backups, want %d", len(backups), tt.want)
+			}
+			// The newest are kept, newest first.
+			for i, b := range backups {
+				if want := ids[len(ids)-1-i]; b.ID != want {
+					t.Errorf("backups[%d] = %s, want %s", i, b.ID, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyWithBackup(t *testing.T) {
+	const keep = 2
+
+	tests := []struct {
+		name   string
+		before string
+		// applied are the policies applied in turn.
+		applied []string
+		// reverted sets the policy back to before ahead of the last apply.
+		reverted    bool
+		wantBackups int
+		// wantRollback is the policy that rolling back the newest backup
+		// restores.
+		wantRollback string
+	}{
+		{
+			name:         "same policy applied more often than backups are kept",
+			before:       "p0",
+			applied:      []string{"p1", "p1", "p1"},
+			wantBackups:  1,
+			wantRollback: "p0",
+		},
+		{
+			name:         "changed policies",
+			before:       "p0",
+			applied:      []string{"p1", "p2"},
+			wantBackups:  2,
+			wantRollback: "p1",
+		},
+		{
+			name:         "policy reverted to the newest backup",
+			before:       "p0",
+			applied:      []string{"p1", "p1"},
+			reverted:     true,
+			wantBackups:  1,
+			wantRollback: "p0",
+		},
+		{
+			name:         "oldest backups pruned",
+			before:       "p0",
+			applied:      []string{"p1", "p2", "p3"},
+			wantBackups:  keep,
+			wantRollback: "p2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_STATE_HOME", t.TempDir())
+			path := filepath.Join(t.TempDir(), "policy.json")
+			writeTestFile(t, path, tt.before)
+			ts := []target{{Kind: targetFile, Location: path}}
+
+			for i, policy := range tt.applied {
+				if tt.reverted && i == len(tt.applied)-1 {
+					writeTestFile(t, path, tt.before)
+				}
+				err := applyWithBackup(ts, "apply", func() error {
+					return os.WriteFile(path, []byte(policy), 0644)
+				})
+				if err != nil {
+					t.Fatalf("applyWithBackup() error = %v", err)
+				}
+				if err := pruneBackups(keep); err != nil {
+					t.Fatalf("pruneBackups() error = %v", err)
+				}
+			}
+
+			backups, err := Backups()
+			if err != nil {
+				t.Fatalf("Backups() error = %v", err)
+			}
+			if len(backups) != tt.wantBackups {
+				t.Fatalf("got %d backups, want %d", len(backups), tt.wantBackups)
+			}
+			if err := Rollback(backups[0], keep); err != nil {
+				t.Fatalf("Rollback() error = %v", err)
+			}
+			if got := readTestFile(t, path); got != tt.wantRollback {
+				t.Errorf("rolled back to %q, want %q", got, tt.wantRollback)
+			}
+		})
+	}
+
+	t.Run("failed apply", func(t *testing.T) {
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		path := filepath.Join(t.TempDir(), "policy.json")
+		writeTestFile(t, path, "p0")
+
+		err := applyWithBackup([]target{{Kind: targetFile, Location: path}}, "apply", func() error {
+			writeTestFile(t, path, "p1")
+			return errors.New("failed")
+		})
+		if err == nil {
+			t.Fatal("applyWithBackup() error = nil, want an error")
+		}
+		if got := readTestFile(t, path); got != "p0" {
+			t.Errorf("policy = %q, want the previous policy restored", got)
+		}
+		backups, err := Backups()
+		if err != nil {
+			t.Fatalf("Backups() error = %v", err)
+		}
+		if len(backups) != 0 {
+			t.Errorf("got %d backups, want the backup removed", len(backups))
+		}
+	})
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setOwned records keys as the ExtensionSettings entries crx wrote to the
+// Firefox policies.json at path.
+func setOwned(t *testing.T, path string, keys []string) {
+	t.Helper()
+	if err := recordFirefoxOwned(path, keys); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setTestFile writes content to path, or removes path if content is nil.
+func setTestFile(t *testing.T, path string, content *string) {
+	t.Helper()
+	if content != nil {
+		writeTestFile(t, path, *content)
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// sameJSON reports whether a and b are the same JSON document.
+func sameJSON(t *testing.T, a, b string) bool {
+	t.Helper()
+	var va, vb any
+	if err := json.Unmarshal([]byte(a), &va); err != nil {
+		t.Fatalf("invalid JSON %s: %v", a, err)
+	}
+	if err := json.Unmarshal([]byte(b), &vb); err != nil {
+		t.Fatalf("invalid JSON %s: %v", b, err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
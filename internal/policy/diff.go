@@ -0,0 +1,138 @@
+package policy
+
+import (
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Kinds of Change.
+const (
+	ChangeAdded           = "added"
+	ChangeRemoved         = "removed"
+	ChangeModeChanged     = "mode_changed"
+	ChangeSettingsChanged = "settings_changed"
+)
+
+// lockdownField is the field reported when the lockdown changes, whether
+// it is expressed by the default settings or the block list.
+const lockdownField = "lockdown"
+
+// Change is a difference in how a policy treats an extension, or in the
+// default settings when ID is DefaultSettingsKey.
+type Change struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+	// From and To are the install modes in the installed and the
+	// generated policy, as reported by Policy.InstallMode.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Fields are the ExtensionSettings fields that differ, such as
+	// update_url or toolbar_pin, for ChangeSettingsChanged.
+	Fields []string `json:"fields,omitempty"`
+}
+
+// Diff compares the installed policy with a generated one and returns
+// the changed default settings, followed by the extensions that would be
+// added, removed, change mode or change settings, ordered by ID.
+func Diff(installed, generated *Policy) []Change {
+	var changes []Change
+	if fields := diffFields(installed.settings(DefaultSettingsKey), generated.settings(DefaultSettingsKey)); len(fields) > 0 {
+		changes = append(changes, Change{Kind: ChangeSettingsChanged, ID: DefaultSettingsKey, Fields: fields})
+	}
+
+	for _, id := range slices.Sorted(slices.Values(union(installed.IDs(), generated.IDs()))) {
+		from, to := installed.InstallMode(id), generated.InstallMode(id)
+		switch {
+		case from == to:
+			if fields := diffFields(installed.settings(id), generated.settings(id)); len(fields) > 0 {
+				changes = append(changes, Change{Kind: ChangeSettingsChanged, ID: id, From: from, To: to, Fields: fields})
+			}
+		case from == "":
+			changes = append(changes, Change{Kind: ChangeAdded, ID: id, To: to})
+		case to == "":
+			changes = append(changes, Change{Kind: ChangeRemoved, ID: id, From: from})
+		default:
+			changes = append(changes, Change{Kind: ChangeModeChanged, ID: id, From: from, To: to})
+		}
+	}
+	return changes
+}
+
+// settings returns the settings of the extension id, or the default
+// settings, as JSON fields. The install mode of an extension is left out,
+// since Diff reports it on its own; the update URL of a force-installed
+// extension is taken from ExtensionInstallForcelist if needed.
+func (p *Policy) settings(id string) map[string]any {
+	fields := make(map[string]any)
+	if setting, ok := p.ExtensionSettings[id]; ok {
+		// Marshaling normalizes empty and missing fields alike.
+		data, _ := json.Marshal(setting)
+		_ = json.Unmarshal(data, &fields)
+	}
+	delete(fields, "installation_mode")
+
+	if id == DefaultSettingsKey {
+		if p.blocksByDefault() {
+			fields[lockdownField] = true
+		}
+		return fields
+	}
+	for _, entry := range p.ExtensionInstallForcelist {
+		if entryID, updateURL, ok := strings.Cut(entry, ";"); ok && entryID == id {
+			if _, set := fields["update_url"]; !set {
+				fields["update_url"] = updateURL
+			}
+		}
+	}
+	return fields
+}
+
+// diffFields returns the names of the fields that differ between a and b,
+// sorted.
+func diffFields(a, b map[string]any) []string {
+	var fields []string
+	for _, field := range slices.Sorted(slices.Values(union(slices.Collect(maps.Keys(a)), slices.Collect(maps.Keys(b))))) {
+		if !reflect.DeepEqual(a[field], b[field]) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// IDs returns the IDs of the extensions that the policy mentions, in the
+// order they first appear. The default settings are not included.
+func (p *Policy) IDs() []string {
+	var ids []string
+	add := func(id string) {
+		if id != DefaultSettingsKey && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	for _, entry := range p.ExtensionInstallForcelist {
+		add(strings.SplitN(entry, ";", 2)[0])
+	}
+	for _, id := range p.ExtensionInstallAllowlist {
+		add(id)
+	}
+	for _, id := range p.ExtensionInstallBlocklist {
+		add(id)
+	}
+	for _, id := range slices.Sorted(maps.Keys(p.ExtensionSettings)) {
+		add(id)
+	}
+	return ids
+}
+
+// union returns the elements of a followed by those of b not in a.
+func union(a, b []string) []string {
+	result := slices.Clone(a)
+	for _, s := range b {
+		if !slices.Contains(result, s) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sivchari/crx/internal/config"
+	"github.com/sivchari/crx/internal/registry"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed *Policy
+		generated *Policy
+		want      []Change
+	}{
+		{
+			name:      "unchanged",
+			installed: &Policy{ExtensionInstallForcelist: []string{idA + ";" + registry.CRXUpdateURL}},
+			generated: &Policy{ExtensionInstallForcelist: []string{idA + ";" + registry.CRXUpdateURL}},
+		},
+		{
+			name:      "added",
+			installed: &Policy{},
+			generated: &Policy{ExtensionInstallForcelist: []string{idA + ";" + registry.CRXUpdateURL}},
+			want:      []Change{{Kind: ChangeAdded, ID: idA, To: config.ModeForceInstall}},
+		},
+		{
+			name:      "removed",
+			installed: &Policy{ExtensionInstallAllowlist: []string{idA}},
+			generated: &Policy{},
+			want:      []Change{{Kind: ChangeRemoved, ID: idA, From: config.ModeAllowed}},
+		},
+		{
+			name:      "mode changed",
+			installed: &Policy{ExtensionInstallAllowlist: []string{idA}},
+			generated: &Policy{ExtensionInstallBlocklist: []string{idA}},
+			want:      []Change{{Kind: ChangeModeChanged, ID: idA, From: config.ModeAllowed, To: ModeBlocked}},
+		},
+		{
+			name:      "update URL changed",
+			installed: &Policy{ExtensionInstallForcelist: []string{idA + ";" + registry.CRXUpdateURL}},
+			generated: &Policy{ExtensionInstallForcelist: []string{idA + ";https://example.com/update.xml"}},
+			want: []Change{{
+				Kind: ChangeSettingsChanged, ID: idA,
+				From: config.ModeForceInstall, To: config.ModeForceInstall,
+				Fields: []string{"update_url"},
+			}},
+		},
+		{
+			name: "options changed",
+			installed: &Policy{
+				ExtensionInstallForcelist: []string{idA + ";" + registry.CRXUpdateURL},
+				ExtensionSettings:         ExtensionSettings{idA: {ToolbarPin: config.ToolbarForcePinned}},
+			},
+			generated: &Policy{
+				ExtensionInstallForcelist: []string{idA + ";" + registry.CRXUpdateURL},
+				ExtensionSettings:         ExtensionSettings{idA: {BlockedPermissions: []string{"tabs"}}},
+			},
+			want: []Change{{
+				Kind: ChangeSettingsChanged, ID: idA,
+				From: config.ModeForceInstall, To: config.ModeForceInstall,
+				Fields: []string{"blocked_permissions", "toolbar_pin"},
+			}},
+		},
+		{
+			name: "normal install unchanged",
+			installed: &Policy{
+				ExtensionSettings: ExtensionSettings{idA: {InstallationMode: InstallationNormalInstalled, UpdateURL: registry.CRXUpdateURL}},
+			},
+			generated: &Policy{
+				ExtensionSettings: ExtensionSettings{idA: {InstallationMode: InstallationNormalInstalled, UpdateURL: registry.CRXUpdateURL}},
+			},
+		},
+		{
+			name:      "lockdown enabled",
+			installed: &Policy{ExtensionInstallAllowlist: []string{idA}},
+			generated: &Policy{
+				ExtensionInstallAllowlist: []string{idA},
+				ExtensionInstallBlocklist: []string{DefaultSettingsKey},
+				ExtensionSettings: ExtensionSettings{DefaultSettingsKey: {
+					InstallationMode:      InstallationBlocked,
+					BlockedInstallMessage: "Ask IT",
+				}},
+			},
+			want: []Change{{Kind: ChangeSettingsChanged, ID: DefaultSettingsKey, Fields: []string{"blocked_install_message", lockdownField}}},
+		},
+		{
+			name: "lockdown by the block list alone",
+			installed: &Policy{
+				ExtensionInstallBlocklist: []string{DefaultSettingsKey},
+			},
+			generated: &Policy{
+				ExtensionSettings: ExtensionSettings{DefaultSettingsKey: {InstallationMode: InstallationBlocked}},
+			},
+		},
+		{
+			name: "defaults first, then by ID",
+			installed: &Policy{
+				ExtensionInstallForcelist: []string{idB + ";" + registry.CRXUpdateURL},
+				ExtensionSettings:         ExtensionSettings{DefaultSettingsKey: {AllowedTypes: []string{"extension"}}},
+			},
+			generated: &Policy{
+				ExtensionInstallAllowlist: []string{idA},
+			},
+			want: []Change{
+				{Kind: ChangeSettingsChanged, ID: DefaultSettingsKey, Fields: []string{"allowed_types"}},
+				{Kind: ChangeAdded, ID: idA, To: config.ModeAllowed},
+				{Kind: ChangeRemoved, ID: idB, From: config.ModeForceInstall},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.installed, tt.generated); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,434 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+
+	"github.com/sivchari/crx/internal/browser"
+	"github.com/sivchari/crx/internal/config"
+	"github.com/sivchari/crx/internal/fsutil"
+	"github.com/sivchari/crx/internal/logger"
+)
+
+// firefoxPoliciesFile is the name of the Firefox enterprise policies file.
+const firefoxPoliciesFile = "policies.json"
+
+// Keys of policies.json: the policies, and the policy written by crx.
+const (
+	firefoxPoliciesKey = "policies"
+	firefoxSettingsKey = "ExtensionSettings"
+)
+
+// firefoxTypes are the extension types that Firefox accepts in
+// allowed_types. Other types only exist in Chrome.
+var firefoxTypes = []string{"extension", "theme"}
+
+// Firefox toolbar areas for default_area.
+const (
+	firefoxAreaNavbar    = "navbar"
+	firefoxAreaMenuPanel = "menupanel"
+)
+
+// generateFirefox generates the Firefox policy. Firefox configures add-ons
+// through ExtensionSettings only, keyed by add-on ID, so packages without
+// a Firefox listing are left out.
+func (g *Generator) generateFirefox() (*Policy, error) {
+	policy := &Policy{}
+	// configured maps the add-on ID of every configured extension to its
+	// index.
+	configured := make(map[string]int, len(g.packages))
+
+	for i, pkg := range g.packages {
+		if pkg.Firefox == nil {
+			logger.Warn("package has no Firefox listing, skipping it for Firefox", "name", pkg.Name)
+			continue
+		}
+
+		ext := g.cfg.Extensions[i]
+		setting := newFirefoxSetting(pkg.Name, ext.ExtensionOptions)
+		switch g.cfg.ModeFor(ext) {
+		case config.ModeForceInstall:
+			setting.InstallationMode = InstallationForceInstalled
+			setting.InstallURL = pkg.Firefox.InstallURL()
+		case config.ModeNormalInstall:
+			setting.InstallationMode = InstallationNormalInstalled
+			setting.InstallURL = pkg.Firefox.InstallURL()
+		case config.ModeAllowed:
+			setting.InstallationMode = InstallationAllowed
+		}
+		configured[pkg.Firefox.AddonID] = i
+		policy.ExtensionSettings.set(pkg.Firefox.AddonID, setting)
+	}
+
+	for _, pkg := range g.blocked {
+		// Extension IDs in the block list are Chrome IDs.
+		if pkg.Firefox == nil {
+			continue
+		}
+		if i, ok := configured[pkg.Firefox.AddonID]; ok {
+			ext := g.cfg.Extensions[i]
+			return nil, fmt.Errorf("extension %s is configured with mode %s but blocked as %s", ext.Key(), g.cfg.ModeFor(ext), pkg.Name)
+		}
+		policy.ExtensionSettings.set(pkg.Firefox.AddonID, &ExtensionSetting{
+			InstallationMode:      InstallationBlocked,
+			BlockedInstallMessage: g.cfg.Settings.BlockedInstallMessage,
+		})
+	}
+
+	settings := g.cfg.Settings
+	if settings.Lockdown || len(settings.AllowedTypes) > 0 || len(settings.InstallSources) > 0 {
+		defaults := &ExtensionSetting{InstallSources: settings.InstallSources}
+		for _, t := range settings.AllowedTypes {
+			if slices.Contains(firefoxTypes, t) {
+				defaults.AllowedTypes = append(defaults.AllowedTypes, t)
+			}
+		}
+		// Configured add-ons have their own installation mode, so blocking
+		// the rest is enough for the lockdown.
+		if settings.Lockdown {
+			defaults.InstallationMode = InstallationBlocked
+			defaults.BlockedInstallMessage = settings.BlockedInstallMessage
+		}
+		policy.ExtensionSettings.set(DefaultSettingsKey, defaults)
+	}
+
+	return policy, nil
+}
+
+// newFirefoxSetting returns the setting carrying the options of an
+// extension that Firefox supports, without an installation mode.
+func newFirefoxSetting(name string, opts config.ExtensionOptions) *ExtensionSetting {
+	setting := &ExtensionSetting{}
+	switch opts.ToolbarPin {
+	case config.ToolbarForcePinned:
+		setting.DefaultArea = firefoxAreaNavbar
+	case config.ToolbarDefaultUnpinned:
+		setting.DefaultArea = firefoxAreaMenuPanel
+	}
+
+	if len(opts.BlockedPermissions) > 0 || len(opts.AllowedPermissions) > 0 ||
+		len(opts.RuntimeBlockedHosts) > 0 || len(opts.RuntimeAllowedHosts) > 0 ||
+		opts.MinimumVersionRequired != "" {
+		logger.Warn("Firefox does not support permission, host and version options; ignoring them", "name", name)
+	}
+	return setting
+}
+
+// firefoxPoliciesPath returns the policies.json read by Firefox.
+func firefoxPoliciesPath(b browser.Browser) string {
+	switch runtime.GOOS {
+	case "darwin":
+		return filepath.Join("/Applications/Firefox.app/Contents/Resources/distribution", firefoxPoliciesFile)
+	case "linux":
+		return filepath.Join(b.LinuxPolicyDir, firefoxPoliciesFile)
+	case "windows":
+		return filepath.Join(os.Getenv("ProgramFiles"), "Mozilla Firefox", "distribution", firefoxPoliciesFile)
+	default:
+		return ""
+	}
+}
+
+// applyFirefox writes the ExtensionSettings policy to the policies.json
+// of Firefox. crx replaces only the entries it wrote before; the entries of
+// other add-ons and the other policies in the file are kept as they are.
+func applyFirefox(policy *Policy, b browser.Browser) error {
+	path := firefoxPoliciesPath(b)
+	if path == "" {
+		return fmt.Errorf("unsupported OS: %s", runtime.GOOS)
+	}
+	doc, err := loadFirefoxDoc(path)
+	if err != nil {
+		return err
+	}
+	owned, err := loadFirefoxOwned(path)
+	if err != nil {
+		return err
+	}
+
+	settings, err := doc.settings()
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, key := range owned {
+		delete(settings, key)
+	}
+	keys := slices.Sorted(maps.Keys(policy.ExtensionSettings))
+	for _, key := range keys {
+		if _, ok := settings[key]; ok {
+			logger.Warn("replacing an ExtensionSettings entry that crx did not write", "addon", key, "path", path)
+		}
+		if settings[key], err = json.Marshal(policy.ExtensionSettings[key]); err != nil {
+			return fmt.Errorf("failed to marshal policy: %w", err)
+		}
+	}
+	if err := doc.setSettings(settings); err != nil {
+		return err
+	}
+	if err := doc.write(path); err != nil {
+		return err
+	}
+	return recordFirefoxOwned(path, keys)
+}
+
+// restoreFirefox restores the ExtensionSettings entries crx wrote, as saved
+// in data, a copy of policies.json, with owned the keys of the entries crx
+// had written then. A nil data means there was no file. Only the entries
+// crx wrote then or since are restored; other entries and policies are
+// kept as they are now. A file left with no policies at all is removed,
+// since crx created it.
+func restoreFirefox(path string, data []byte, owned []string) error {
+	doc, err := loadFirefoxDoc(path)
+	if err != nil {
+		return err
+	}
+	saved := newFirefoxDoc()
+	if data != nil {
+		if saved, err = parseFirefoxDoc(data); err != nil {
+			return fmt.Errorf("failed to parse the saved %s: %w", path, err)
+		}
+	}
+	current, err := loadFirefoxOwned(path)
+	if err != nil {
+		return err
+	}
+
+	settings, err := doc.settings()
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	savedSettings, err := saved.settings()
+	if err != nil {
+		return fmt.Errorf("failed to parse the saved %s: %w", path, err)
+	}
+	for _, key := range union(current, owned) {
+		if raw, ok := savedSettings[key]; ok {
+			settings[key] = raw
+		} else {
+			delete(settings, key)
+		}
+	}
+	if err := doc.setSettings(settings); err != nil {
+		return err
+	}
+
+	if err := doc.restore(path, saved, data); err != nil {
+		return err
+	}
+	return recordFirefoxOwned(path, owned)
+}
+
+// restore writes the restored document to path. Unless the file changed
+// since data was saved, the saved copy is written as it was, formatting
+// included.
+func (d *firefoxDoc) restore(path string, saved *firefoxDoc, data []byte) error {
+	if data == nil && d.empty() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		return nil
+	}
+	if data != nil {
+		equal, err := d.equal(saved)
+		if err != nil {
+			return err
+		}
+		if equal {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return fmt.Errorf("failed to create policy directory: %w", err)
+			}
+			return fsutil.WriteFileAtomic(path, data, 0644)
+		}
+	}
+	return d.write(path)
+}
+
+// firefoxDoc is a policies.json document. Only the ExtensionSettings
+// policy is decoded; everything else is kept verbatim.
+type firefoxDoc struct {
+	doc      map[string]json.RawMessage
+	policies map[string]json.RawMessage
+}
+
+// newFirefoxDoc returns an empty document.
+func newFirefoxDoc() *firefoxDoc {
+	return &firefoxDoc{doc: make(map[string]json.RawMessage), policies: make(map[string]json.RawMessage)}
+}
+
+// loadFirefoxDoc reads the policies.json at path. A missing file yields an
+// empty document.
+func loadFirefoxDoc(path string) (*firefoxDoc, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return newFirefoxDoc(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	doc, err := parseFirefoxDoc(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return doc, nil
+}
+
+// parseFirefoxDoc parses the content of a policies.json.
+func parseFirefoxDoc(data []byte) (*firefoxDoc, error) {
+	var doc, policies map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if raw, ok := doc[firefoxPoliciesKey]; ok {
+		if err := json.Unmarshal(raw, &policies); err != nil {
+			return nil, err
+		}
+	}
+	// A null document or "policies": null decodes to a nil map.
+	if doc == nil {
+		doc = make(map[string]json.RawMessage)
+	}
+	if policies == nil {
+		policies = make(map[string]json.RawMessage)
+	}
+	return &firefoxDoc{doc: doc, policies: policies}, nil
+}
+
+// equal reports whether d and other are the same document apart from
+// formatting.
+func (d *firefoxDoc) equal(other *firefoxDoc) (bool, error) {
+	a, err := d.canonical()
+	if err != nil {
+		return false, err
+	}
+	b, err := other.canonical()
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(a, b), nil
+}
+
+// canonical returns the document as compact JSON, with the keys of the
+// document, the policies and ExtensionSettings sorted.
+func (d *firefoxDoc) canonical() ([]byte, error) {
+	policies := maps.Clone(d.policies)
+	if _, ok := policies[firefoxSettingsKey]; ok {
+		settings, err := d.settings()
+		if err != nil {
+			return nil, err
+		}
+		if policies[firefoxSettingsKey], err = json.Marshal(settings); err != nil {
+			return nil, fmt.Errorf("failed to marshal policy: %w", err)
+		}
+	}
+	doc := maps.Clone(d.doc)
+
+	var err error
+	if doc[firefoxPoliciesKey], err = json.Marshal(policies); err != nil {
+		return nil, fmt.Errorf("failed to marshal policy: %w", err)
+	}
+	return json.Marshal(doc)
+}
+
+// settings returns the entries of the ExtensionSettings policy by key,
+// each kept verbatim.
+func (d *firefoxDoc) settings() (map[string]json.RawMessage, error) {
+	var settings map[string]json.RawMessage
+	if raw, ok := d.policies[firefoxSettingsKey]; ok {
+		if err := json.Unmarshal(raw, &settings); err != nil {
+			return nil, err
+		}
+	}
+	if settings == nil {
+		settings = make(map[string]json.RawMessage)
+	}
+	return settings, nil
+}
+
+// setSettings replaces the ExtensionSettings policy, or removes it if
+// settings is empty.
+func (d *firefoxDoc) setSettings(settings map[string]json.RawMessage) error {
+	if len(settings) == 0 {
+		delete(d.policies, firefoxSettingsKey)
+		return nil
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal policy: %w", err)
+	}
+	d.policies[firefoxSettingsKey] = data
+	return nil
+}
+
+// empty reports whether the document holds no policies and nothing else.
+func (d *firefoxDoc) empty() bool {
+	if len(d.policies) > 0 {
+		return false
+	}
+	for key := range d.doc {
+		if key != firefoxPoliciesKey {
+			return false
+		}
+	}
+	return true
+}
+
+// write writes the document to path.
+func (d *firefoxDoc) write(path string) error {
+	var err error
+	if d.doc[firefoxPoliciesKey], err = json.Marshal(d.policies); err != nil {
+		return fmt.Errorf("failed to marshal policy: %w", err)
+	}
+	out, err := json.MarshalIndent(d.doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal policy: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create policy directory: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(path, append(out, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write policy file: %w", err)
+	}
+	return nil
+}
+
+// readFirefox reads the ExtensionSettings entries that crx wrote to
+// policies.json.
+func readFirefox(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Policy{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	policy, err := parseFirefox(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+	owned, err := loadFirefoxOwned(path)
+	if err != nil {
+		return nil, err
+	}
+	maps.DeleteFunc(policy.ExtensionSettings, func(key string, _ *ExtensionSetting) bool {
+		return !slices.Contains(owned, key)
+	})
+	return policy, nil
+}
+
+// parseFirefox parses the ExtensionSettings policy of a policies.json.
+func parseFirefox(data []byte) (*Policy, error) {
+	var doc struct {
+		Policies Policy `json:"policies"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return &doc.Policies, nil
+}
@@ -0,0 +1,105 @@
+package policy
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/sivchari/crx/internal/browser"
+	"github.com/sivchari/crx/internal/registry"
+)
+
+func TestApplyFirefox(t *testing.T) {
+	const admin = `"admin@example.org": {"installation_mode": "force_installed", "updates_disabled": true}`
+
+	allowed := ExtensionSettings{vimiumAddonID: {InstallationMode: InstallationAllowed}}
+
+	tests := []struct {
+		name   string
+		before *string
+		// owned are the entries crx wrote before.
+		owned     []string
+		settings  ExtensionSettings
+		want      string
+		wantOwned []string
+	}{
+		{
+			name:      "new file",
+			settings:  allowed,
+			want:      `{"policies": {"ExtensionSettings": {"vimium@example.org": {"installation_mode": "allowed"}}}}`,
+			wantOwned: []string{vimiumAddonID},
+		},
+		{
+			name:      "other policies and add-ons kept",
+			before:    ptr(`{"policies": {"DisableTelemetry": true, "ExtensionSettings": {` + admin + `}}}`),
+			settings:  allowed,
+			want:      `{"policies": {"DisableTelemetry": true, "ExtensionSettings": {` + admin + `, "vimium@example.org": {"installation_mode": "allowed"}}}}`,
+			wantOwned: []string{vimiumAddonID},
+		},
+		{
+			name:      "entries crx wrote before replaced",
+			before:    ptr(`{"policies": {"ExtensionSettings": {` + admin + `, "addon@darkreader.org": {"installation_mode": "allowed"}}}}`),
+			owned:     []string{darkReaderAddonID},
+			settings:  allowed,
+			want:      `{"policies": {"ExtensionSettings": {` + admin + `, "vimium@example.org": {"installation_mode": "allowed"}}}}`,
+			wantOwned: []string{vimiumAddonID},
+		},
+		{
+			name:   "add-ons of the admin kept when crx writes none",
+			before: ptr(`{"policies": {"ExtensionSettings": {` + admin + `, "vimium@example.org": {"installation_mode": "allowed"}}}}`),
+			owned:  []string{vimiumAddonID},
+			want:   `{"policies": {"ExtensionSettings": {` + admin + `}}}`,
+		},
+		{
+			name:   "ExtensionSettings removed when empty",
+			before: ptr(`{"policies": {"DisableTelemetry": true, "ExtensionSettings": {"vimium@example.org": {"installation_mode": "allowed"}}}}`),
+			owned:  []string{vimiumAddonID},
+			want:   `{"policies": {"DisableTelemetry": true}}`,
+		},
+		{
+			name:      "entry of the admin for a configured add-on replaced",
+			before:    ptr(`{"policies": {"ExtensionSettings": {"vimium@example.org": {"installation_mode": "blocked"}}}}`),
+			settings:  allowed,
+			want:      `{"policies": {"ExtensionSettings": {"vimium@example.org": {"installation_mode": "allowed"}}}}`,
+			wantOwned: []string{vimiumAddonID},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_STATE_HOME", t.TempDir())
+			b := browser.Browser{Name: browser.Firefox, LinuxPolicyDir: t.TempDir(), Store: registry.StoreFirefox}
+			path := firefoxPoliciesPath(b)
+			if path == "" || filepath.Dir(path) != b.LinuxPolicyDir {
+				t.Skip("policies.json is only written to the policy directory on Linux")
+			}
+			setTestFile(t, path, tt.before)
+			setOwned(t, path, tt.owned)
+
+			if err := applyFirefox(&Policy{ExtensionSettings: tt.settings}, b); err != nil {
+				t.Fatalf("applyFirefox() error = %v", err)
+			}
+
+			if got := readTestFile(t, path); !sameJSON(t, got, tt.want) {
+				t.Errorf("policies.json = %s, want %s", got, tt.want)
+			}
+			owned, err := loadFirefoxOwned(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(owned, tt.wantOwned) {
+				t.Errorf("owned entries = %v, want %v", owned, tt.wantOwned)
+			}
+
+			// Only the entries crx wrote are reported as installed.
+			installed, err := readFirefox(path)
+			if err != nil {
+				t.Fatalf("readFirefox() error = %v", err)
+			}
+			if got := slices.Sorted(maps.Keys(installed.ExtensionSettings)); !slices.Equal(got, tt.wantOwned) {
+				t.Errorf("readFirefox() entries = %v, want %v", got, tt.wantOwned)
+			}
+		})
+	}
+}
@@ -2,151 +2,462 @@ package policy
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 
+	"github.com/sivchari/crx/internal/browser"
 	"github.com/sivchari/crx/internal/config"
+	"github.com/sivchari/crx/internal/fsutil"
+	"github.com/sivchari/crx/internal/logger"
 	"github.com/sivchari/crx/internal/registry"
 )
 
 // Policy represents Chrome Enterprise Policy for extensions.
 type Policy struct {
-	ExtensionInstallForcelist []string `json:"ExtensionInstallForcelist,omitempty"`
-	ExtensionInstallAllowlist []string `json:"ExtensionInstallAllowlist,omitempty"`
-	ExtensionInstallBlocklist []string `json:"ExtensionInstallBlocklist,omitempty"`
-	ExtensionSettings         any      `json:"ExtensionSettings,omitempty"`
+	ExtensionInstallForcelist []string          `json:"ExtensionInstallForcelist,omitempty"`
+	ExtensionInstallAllowlist []string          `json:"ExtensionInstallAllowlist,omitempty"`
+	ExtensionInstallBlocklist []string          `json:"ExtensionInstallBlocklist,omitempty"`
+	ExtensionSettings         ExtensionSettings `json:"ExtensionSettings,omitempty"`
+}
+
+// Policies are generated policies keyed by extension store. Browsers
+// installing from the same store share a policy.
+type Policies map[string]*Policy
+
+// For returns the policy of the browser.
+func (p Policies) For(b browser.Browser) *Policy {
+	return p[b.Store]
+}
+
+// policyFileName is the name of the policy file in Linux policy directories.
+const policyFileName = "crx-extensions.json"
+
+// darwinManagedPrefsDir is where macOS stores the preferences managed by
+// installed configuration profiles.
+const darwinManagedPrefsDir = "/Library/Managed Preferences"
+
+// ModeBlocked is reported by InstallMode for blocked extensions.
+const ModeBlocked = "blocked"
+
+// InstallMode returns the install mode of the extension id in the policy,
+// using the config mode constants, or "blocked" if it is blocked.
+// An empty string means the policy does not mention the extension.
+func (p *Policy) InstallMode(id string) string {
+	if setting, ok := p.ExtensionSettings[id]; ok {
+		switch setting.InstallationMode {
+		case InstallationForceInstalled:
+			return config.ModeForceInstall
+		case InstallationNormalInstalled:
+			return config.ModeNormalInstall
+		case InstallationAllowed:
+			return config.ModeAllowed
+		case InstallationBlocked, InstallationRemoved:
+			return ModeBlocked
+		}
+	}
+	for _, entry := range p.ExtensionInstallForcelist {
+		if strings.SplitN(entry, ";", 2)[0] == id {
+			return config.ModeForceInstall
+		}
+	}
+	if slices.Contains(p.ExtensionInstallBlocklist, id) {
+		return ModeBlocked
+	}
+	if slices.Contains(p.ExtensionInstallAllowlist, id) {
+		return config.ModeAllowed
+	}
+	return ""
+}
+
+// empty reports whether the policy configures no extension at all.
+func (p *Policy) empty() bool {
+	return len(p.IDs()) == 0 && len(p.ExtensionSettings) == 0
+}
+
+// Allows reports whether the policy lets users keep the extension id.
+func (p *Policy) Allows(id string) bool {
+	switch p.InstallMode(id) {
+	case ModeBlocked:
+		return false
+	case "":
+		return !p.blocksByDefault()
+	default:
+		return true
+	}
+}
+
+// blocksByDefault reports whether extensions that the policy does not
+// mention are blocked.
+func (p *Policy) blocksByDefault() bool {
+	if setting, ok := p.ExtensionSettings[DefaultSettingsKey]; ok && setting.InstallationMode != "" {
+		return setting.InstallationMode == InstallationBlocked
+	}
+	return slices.Contains(p.ExtensionInstallBlocklist, DefaultSettingsKey)
+}
+
+// ReadInstalled reads the policy currently installed for the browser.
+// It returns an empty policy if none is installed.
+func ReadInstalled(b browser.Browser) (*Policy, error) {
+	if b.IsFirefox() {
+		return readFirefox(firefoxPoliciesPath(b))
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		return readDarwin(b.MacDomain)
+	case "linux":
+		return readLinux(b.LinuxPolicyDir)
+	case "windows":
+		return readWindows(b.WindowsPolicyKey)
+	default:
+		return nil, fmt.Errorf("unsupported OS: %s", runtime.GOOS)
+	}
+}
+
+// readLinux reads the policy JSON file written by applyLinux.
+func readLinux(dir string) (*Policy, error) {
+	data, err := os.ReadFile(filepath.Join(dir, policyFileName))
+	if os.IsNotExist(err) {
+		return &Policy{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+	return &policy, nil
+}
+
+// readDarwin reads the managed preferences of the domain.
+func readDarwin(domain string) (*Policy, error) {
+	path := filepath.Join(darwinManagedPrefsDir, domain+".plist")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return &Policy{}, nil
+	}
+
+	// Managed preferences are binary plists; plutil converts them to JSON.
+	out, err := exec.Command("plutil", "-convert", "json", "-o", "-", path).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read managed preferences: %w", err)
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(out, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse managed preferences: %w", err)
+	}
+	return &policy, nil
 }
 
 // Generator generates Chrome Enterprise Policy JSON.
 type Generator struct {
 	cfg      *config.Config
 	packages []*registry.Package
+	blocked  []*registry.Package
 }
 
 // NewGenerator creates a new Generator.
-func NewGenerator(cfg *config.Config, packages []*registry.Package) *Generator {
+// packages must be in the order of cfg.Extensions, and blocked are the
+// packages of cfg.Block.
+func NewGenerator(cfg *config.Config, packages, blocked []*registry.Package) *Generator {
 	return &Generator{
 		cfg:      cfg,
 		packages: packages,
+		blocked:  blocked,
 	}
 }
 
-// Generate generates the policy based on the configuration.
-func (g *Generator) Generate() (*Policy, error) {
-	policy := &Policy{}
+// Generate generates the policy of every extension store used by the
+// configured browsers.
+func (g *Generator) Generate() (Policies, error) {
+	if len(g.packages) != len(g.cfg.Extensions) {
+		return nil, fmt.Errorf("got %d packages for %d configured extensions", len(g.packages), len(g.cfg.Extensions))
+	}
+
+	browsers, err := g.cfg.EffectiveBrowsers()
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make(Policies)
+	for _, b := range browsers {
+		if _, ok := policies[b.Store]; ok {
+			continue
+		}
+		generate := func() (*Policy, error) { return g.generate(b.Store, browsers) }
+		if b.IsFirefox() {
+			generate = g.generateFirefox
+		}
+		policy, err := generate()
+		if err != nil {
+			return nil, err
+		}
+		policies[b.Store] = policy
+	}
+	return policies, nil
+}
 
-	for _, pkg := range g.packages {
-		entry := formatEntry(pkg.ID)
+// generate generates the policy for the browsers installing from store.
+func (g *Generator) generate(store string, browsers []browser.Browser) (*Policy, error) {
+	policy := &Policy{}
+	// configured maps the ID of every configured extension to its index.
+	configured := make(map[string]int, len(g.packages))
+	// ids are the store IDs of the configured extensions.
+	ids := make([]string, len(g.packages))
+
+	for i, pkg := range g.packages {
+		listing, ok := pkg.Listing(store)
+		if !ok {
+			logger.Warn("package has no listing for the browser store, using its Chrome Web Store listing",
+				"name", pkg.Name, "store", store, "browsers", storeBrowsers(store, browsers))
+		}
+		if err := registry.ValidateUpdateURL(listing.UpdateURL, g.cfg.Settings.InsecureUpdateHosts); err != nil {
+			return nil, fmt.Errorf("extension %s: %w", pkg.Name, err)
+		}
+		entry := formatEntry(listing.ID, listing.UpdateURL)
 
-		switch g.cfg.Settings.Mode {
+		ext := g.cfg.Extensions[i]
+		configured[listing.ID] = i
+		ids[i] = listing.ID
+		switch g.cfg.ModeFor(ext) {
 		case config.ModeForceInstall:
 			policy.ExtensionInstallForcelist = append(policy.ExtensionInstallForcelist, entry)
 		case config.ModeNormalInstall:
 			// normal_install uses ExtensionSettings
-			if policy.ExtensionSettings == nil {
-				policy.ExtensionSettings = make(map[string]any)
-			}
-			settings := policy.ExtensionSettings.(map[string]any)
-			settings[pkg.ID] = map[string]any{
-				"installation_mode": "normal_installed",
-				"update_url":        registry.CRXUpdateURL,
-			}
+			setting := newExtensionSetting(ext.ExtensionOptions)
+			setting.InstallationMode = InstallationNormalInstalled
+			setting.UpdateURL = listing.UpdateURL
+			policy.ExtensionSettings.set(listing.ID, setting)
+			continue
 		case config.ModeAllowed:
-			policy.ExtensionInstallAllowlist = append(policy.ExtensionInstallAllowlist, pkg.ID)
+			policy.ExtensionInstallAllowlist = append(policy.ExtensionInstallAllowlist, listing.ID)
+		}
+
+		// Options of list-based modes go into ExtensionSettings on their own;
+		// Chrome combines them with the install lists.
+		if !ext.ExtensionOptions.IsZero() {
+			policy.ExtensionSettings.set(listing.ID, newExtensionSetting(ext.ExtensionOptions))
+		}
+	}
+
+	for _, pkg := range g.blocked {
+		// Blocking the Chrome Web Store ID is still meaningful in browsers
+		// of other stores, so a missing listing is not reported.
+		listing, _ := pkg.Listing(store)
+		if i, ok := configured[listing.ID]; ok {
+			ext := g.cfg.Extensions[i]
+			return nil, fmt.Errorf("extension %s is configured with mode %s but blocked as %s", ext.Key(), g.cfg.ModeFor(ext), pkg.Name)
+		}
+		if slices.Contains(policy.ExtensionInstallBlocklist, listing.ID) {
+			continue
 		}
+		policy.ExtensionInstallBlocklist = append(policy.ExtensionInstallBlocklist, listing.ID)
+		policy.ExtensionSettings.set(listing.ID, &ExtensionSetting{
+			InstallationMode:      InstallationBlocked,
+			BlockedInstallMessage: g.cfg.Settings.BlockedInstallMessage,
+		})
 	}
 
+	g.applyDefaults(policy, ids)
+
 	return policy, nil
 }
 
+// storeBrowsers returns the names of the browsers installing from store.
+func storeBrowsers(store string, browsers []browser.Browser) []string {
+	var names []string
+	for _, b := range browsers {
+		if b.Store == store {
+			names = append(names, b.Name)
+		}
+	}
+	return names
+}
+
+// applyDefaults adds the settings for extensions that are not configured:
+// the lockdown with its blocked install message, and the allowed types and
+// install sources. ids are the IDs of the configured extensions.
+func (g *Generator) applyDefaults(policy *Policy, ids []string) {
+	settings := g.cfg.Settings
+	if !settings.Lockdown && len(settings.AllowedTypes) == 0 && len(settings.InstallSources) == 0 {
+		return
+	}
+
+	defaults := &ExtensionSetting{
+		AllowedTypes:   settings.AllowedTypes,
+		InstallSources: settings.InstallSources,
+	}
+
+	if settings.Lockdown {
+		// Block everything, then allow each configured extension explicitly.
+		defaults.InstallationMode = InstallationBlocked
+		defaults.BlockedInstallMessage = settings.BlockedInstallMessage
+		policy.ExtensionInstallBlocklist = append([]string{DefaultSettingsKey}, policy.ExtensionInstallBlocklist...)
+		for _, id := range ids {
+			if !slices.Contains(policy.ExtensionInstallAllowlist, id) {
+				policy.ExtensionInstallAllowlist = append(policy.ExtensionInstallAllowlist, id)
+			}
+		}
+	}
+
+	policy.ExtensionSettings.set(DefaultSettingsKey, defaults)
+}
+
 // formatEntry formats the extension ID with the update URL.
-func formatEntry(id string) string {
-	return fmt.Sprintf("%s;%s", id, registry.CRXUpdateURL)
+func formatEntry(id, updateURL string) string {
+	return fmt.Sprintf("%s;%s", id, updateURL)
 }
 
-// Apply applies the policy to the system using the appropriate method for the OS.
-func (g *Generator) Apply(policy *Policy) error {
+// Apply applies the policies to the system using the appropriate method for
+// the OS, for every configured browser, and removes the crx policy of
+// browsers that are no longer configured. The policies it replaces are
+// backed up first and restored if applying fails; the backup is only kept
+// if it is needed to roll back the change.
+func (g *Generator) Apply(policies Policies) error {
+	all, err := g.cfg.EffectiveBrowsers()
+	if err != nil {
+		return err
+	}
+	stale, err := Stale(all)
+	if err != nil {
+		return err
+	}
+	ts, err := targets(append(slices.Clone(all), stale...))
+	if err != nil {
+		return err
+	}
+
+	err = applyWithBackup(ts, "apply", func() error {
+		if err := g.apply(policies, all); err != nil {
+			return err
+		}
+		return removeStale(stale, all)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := recordApplied(browserNames(all)); err != nil {
+		return err
+	}
+	return pruneBackups(g.cfg.EffectiveBackups())
+}
+
+// apply writes the policies of the browsers.
+func (g *Generator) apply(policies Policies, all []browser.Browser) error {
+	var browsers []browser.Browser
+	for _, b := range all {
+		if !b.IsFirefox() {
+			browsers = append(browsers, b)
+			continue
+		}
+		if err := applyFirefox(policies.For(b), b); err != nil {
+			return err
+		}
+	}
+	if len(browsers) == 0 {
+		return nil
+	}
+
 	switch runtime.GOOS {
 	case "darwin":
-		return g.applyDarwin(policy)
+		return g.applyDarwin(policies, browsers)
 	case "linux":
-		return g.applyLinux(policy)
+		return g.applyLinux(policies, browsers)
 	case "windows":
-		return g.applyWindows(policy)
+		return g.applyWindows(policies, browsers)
 	default:
 		return fmt.Errorf("unsupported OS: %s", runtime.GOOS)
 	}
 }
 
+// uniqueLocations returns the first of browsers for each distinct policy
+// location, in order. Chrome channels share locations on some platforms.
+func uniqueLocations(browsers []browser.Browser, location func(browser.Browser) string) []browser.Browser {
+	var unique []browser.Browser
+	for _, b := range browsers {
+		if !slices.ContainsFunc(unique, func(u browser.Browser) bool { return location(u) == location(b) }) {
+			unique = append(unique, b)
+		}
+	}
+	return unique
+}
+
 // applyDarwin applies the policy on macOS using a configuration profile.
 // Since macOS Big Sur, profiles cannot be installed silently.
 // This generates the profile and opens System Settings for manual installation.
-func (g *Generator) applyDarwin(policy *Policy) error {
+func (g *Generator) applyDarwin(policies Policies, browsers []browser.Browser) error {
 	// Generate mobileconfig content
-	mobileconfig := buildMobileconfig(policy)
+	domains := make(map[string]*Policy)
+	for _, b := range uniqueLocations(browsers, func(b browser.Browser) string { return b.MacDomain }) {
+		domains[b.MacDomain] = policies.For(b)
+	}
+	mobileconfig, err := buildMobileconfig(domains)
+	if err != nil {
+		return fmt.Errorf("failed to build mobileconfig: %w", err)
+	}
 
 	// Determine output path
-	homeDir, err := os.UserHomeDir()
+	profilePath, err := mobileconfigPath()
 	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
+		return err
 	}
-	profilePath := filepath.Join(homeDir, "Desktop", "crx-chrome-policy.mobileconfig")
 
 	// Write the profile file
-	if err := os.WriteFile(profilePath, []byte(mobileconfig), 0644); err != nil {
+	if err := fsutil.WriteFileAtomic(profilePath, []byte(mobileconfig), 0644); err != nil {
 		return fmt.Errorf("failed to write mobileconfig: %w", err)
 	}
 
-	// Open System Settings with the profile
-	cmd := exec.Command("open", profilePath)
+	return openProfile(profilePath)
+}
+
+// mobileconfigPath returns where the configuration profile is written.
+func mobileconfigPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, "Desktop", "crx-chrome-policy.mobileconfig"), nil
+}
+
+// openProfile opens System Settings with the configuration profile.
+func openProfile(path string) error {
+	cmd := exec.Command("open", path)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to open profile: %w", err)
 	}
-
 	return nil
 }
 
-// buildMobileconfig generates a macOS configuration profile for Chrome policies.
-func buildMobileconfig(policy *Policy) string {
-	var mcxSettings string
-
-	if len(policy.ExtensionInstallForcelist) > 0 {
-		mcxSettings += "\t\t\t\t\t\t\t\t<key>ExtensionInstallForcelist</key>\n\t\t\t\t\t\t\t\t<array>\n"
-		for _, entry := range policy.ExtensionInstallForcelist {
-			mcxSettings += fmt.Sprintf("\t\t\t\t\t\t\t\t\t<string>%s</string>\n", entry)
+// buildMobileconfig generates a macOS configuration profile for Chrome
+// policies, keyed by preference domain.
+func buildMobileconfig(domains map[string]*Policy) (string, error) {
+	var payload strings.Builder
+	for _, domain := range slices.Sorted(maps.Keys(domains)) {
+		// Encode through JSON so the plist carries exactly the keys of the
+		// JSON policy, including nested ExtensionSettings dictionaries.
+		data, err := json.Marshal(domains[domain])
+		if err != nil {
+			return "", err
 		}
-		mcxSettings += "\t\t\t\t\t\t\t\t</array>\n"
-	}
-
-	if len(policy.ExtensionInstallAllowlist) > 0 {
-		mcxSettings += "\t\t\t\t\t\t\t\t<key>ExtensionInstallAllowlist</key>\n\t\t\t\t\t\t\t\t<array>\n"
-		for _, entry := range policy.ExtensionInstallAllowlist {
-			mcxSettings += fmt.Sprintf("\t\t\t\t\t\t\t\t\t<string>%s</string>\n", entry)
+		var values map[string]any
+		if err := json.Unmarshal(data, &values); err != nil {
+			return "", err
 		}
-		mcxSettings += "\t\t\t\t\t\t\t\t</array>\n"
-	}
 
-	if len(policy.ExtensionInstallBlocklist) > 0 {
-		mcxSettings += "\t\t\t\t\t\t\t\t<key>ExtensionInstallBlocklist</key>\n\t\t\t\t\t\t\t\t<array>\n"
-		for _, entry := range policy.ExtensionInstallBlocklist {
-			mcxSettings += fmt.Sprintf("\t\t\t\t\t\t\t\t\t<string>%s</string>\n", entry)
-		}
-		mcxSettings += "\t\t\t\t\t\t\t\t</array>\n"
-	}
+		var mcxSettings strings.Builder
+		writePlistEntries(&mcxSettings, values, 8)
 
-	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
-<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
-<plist version="1.0">
-<dict>
-	<key>PayloadContent</key>
-	<array>
-		<dict>
-			<key>PayloadContent</key>
-			<dict>
-				<key>com.google.Chrome</key>
+		fmt.Fprintf(&payload, `				<key>%s</key>
 				<dict>
 					<key>Forced</key>
 					<array>
@@ -157,7 +468,19 @@ func buildMobileconfig(policy *Policy) string {
 						</dict>
 					</array>
 				</dict>
-			</dict>
+`, xmlEscape(domain), mcxSettings.String())
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>PayloadContent</key>
+	<array>
+		<dict>
+			<key>PayloadContent</key>
+			<dict>
+%s			</dict>
 			<key>PayloadEnabled</key>
 			<true/>
 			<key>PayloadIdentifier</key>
@@ -190,26 +513,66 @@ func buildMobileconfig(policy *Policy) string {
 	<integer>1</integer>
 </dict>
 </plist>
-`, mcxSettings)
+`, payload.String()), nil
 }
 
-// applyLinux applies the policy on Linux using JSON file.
-func (g *Generator) applyLinux(policy *Policy) error {
-	dir := "/etc/opt/chrome/policies/managed"
+// writePlistEntries writes the keys and values of a dictionary, sorted by
+// key, indented by depth tabs. Values are JSON-decoded.
+func writePlistEntries(b *strings.Builder, dict map[string]any, depth int) {
+	indent := strings.Repeat("\t", depth)
+	for _, key := range slices.Sorted(maps.Keys(dict)) {
+		fmt.Fprintf(b, "%s<key>%s</key>\n", indent, xmlEscape(key))
+		writePlistValue(b, dict[key], depth)
+	}
+}
 
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create policy directory: %w", err)
+// writePlistValue writes a JSON-decoded value as a plist value.
+func writePlistValue(b *strings.Builder, v any, depth int) {
+	indent := strings.Repeat("\t", depth)
+	switch v := v.(type) {
+	case map[string]any:
+		fmt.Fprintf(b, "%s<dict>\n", indent)
+		writePlistEntries(b, v, depth+1)
+		fmt.Fprintf(b, "%s</dict>\n", indent)
+	case []any:
+		fmt.Fprintf(b, "%s<array>\n", indent)
+		for _, item := range v {
+			writePlistValue(b, item, depth+1)
+		}
+		fmt.Fprintf(b, "%s</array>\n", indent)
+	case string:
+		fmt.Fprintf(b, "%s<string>%s</string>\n", indent, xmlEscape(v))
+	case bool:
+		fmt.Fprintf(b, "%s<%t/>\n", indent, v)
+	case float64:
+		fmt.Fprintf(b, "%s<integer>%d</integer>\n", indent, int64(v))
 	}
+}
 
-	path := filepath.Join(dir, "crx-extensions.json")
+// xmlEscape escapes s for use as XML character data.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
 
-	data, err := json.MarshalIndent(policy, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal policy: %w", err)
-	}
+// applyLinux applies the policy on Linux using JSON file.
+func (g *Generator) applyLinux(policies Policies, browsers []browser.Browser) error {
+	for _, b := range uniqueLocations(browsers, func(b browser.Browser) string { return b.LinuxPolicyDir }) {
+		data, err := json.MarshalIndent(policies.For(b), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal policy: %w", err)
+		}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
-		return fmt.Errorf("failed to write policy file: %w", err)
+		dir := b.LinuxPolicyDir
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create policy directory: %w", err)
+		}
+
+		path := filepath.Join(dir, policyFileName)
+		if err := fsutil.WriteFileAtomic(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write policy file: %w", err)
+		}
 	}
 
 	return nil
@@ -250,45 +613,20 @@ func (g *Generator) ToJSON(policy *Policy) (string, error) {
 	return string(data), nil
 }
 
-// Diff compares the generated policy with the existing one.
-func (g *Generator) Diff(policy *Policy, filename string) (bool, string, error) {
-	dir := g.cfg.Settings.PolicyPath
-	if dir == "" {
-		return false, "", fmt.Errorf("policy_path is not set")
-	}
-
-	path := filepath.Join(dir, filename)
-
-	existing, err := os.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			newJSON, _ := g.ToJSON(policy)
-			return true, fmt.Sprintf("New file will be created:\n%s", newJSON), nil
-		}
-		return false, "", fmt.Errorf("failed to read existing policy: %w", err)
+// Location returns where the policy of the browser is stored on the
+// current OS.
+func Location(b browser.Browser) string {
+	if b.IsFirefox() {
+		return firefoxPoliciesPath(b)
 	}
 
-	newJSON, err := g.ToJSON(policy)
-	if err != nil {
-		return false, "", err
-	}
-
-	if string(existing) == newJSON {
-		return false, "No changes", nil
-	}
-
-	return true, fmt.Sprintf("Changes detected:\n--- existing\n%s\n+++ new\n%s", string(existing), newJSON), nil
-}
-
-// GetPolicyPath returns the appropriate policy path for the current OS.
-func GetPolicyPath() string {
 	switch runtime.GOOS {
 	case "darwin":
-		return "Configuration Profile (com.crx.chrome.extensions)"
+		return fmt.Sprintf("Configuration Profile (com.crx.chrome.extensions, %s)", b.MacDomain)
 	case "linux":
-		return "/etc/opt/chrome/policies/managed"
+		return filepath.Join(b.LinuxPolicyDir, policyFileName)
 	case "windows":
-		return "HKLM\\SOFTWARE\\Policies\\Google\\Chrome"
+		return `HKLM\` + b.WindowsPolicyKey
 	default:
 		return ""
 	}
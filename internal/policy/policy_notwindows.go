@@ -2,14 +2,33 @@
 
 package policy
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/sivchari/crx/internal/browser"
+)
 
 // applyWindows is a stub for non-Windows platforms.
-func (g *Generator) applyWindows(policy *Policy) error {
+func (g *Generator) applyWindows(policies Policies, browsers []browser.Browser) error {
 	return fmt.Errorf("windows support is only available on Windows")
 }
 
 // RemoveWindowsPolicy is a stub for non-Windows platforms.
-func RemoveWindowsPolicy() error {
+func RemoveWindowsPolicy(b browser.Browser) error {
+	return fmt.Errorf("windows support is only available on Windows")
+}
+
+// readWindows is a stub for non-Windows platforms.
+func readWindows(key string) (*Policy, error) {
+	return nil, fmt.Errorf("windows support is only available on Windows")
+}
+
+// readWindowsSnapshot is a stub for non-Windows platforms.
+func readWindowsSnapshot(key string) ([]byte, error) {
+	return nil, fmt.Errorf("windows support is only available on Windows")
+}
+
+// restoreWindows is a stub for non-Windows platforms.
+func restoreWindows(key string, data []byte) error {
 	return fmt.Errorf("windows support is only available on Windows")
 }
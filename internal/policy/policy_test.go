@@ -0,0 +1,232 @@
+package policy
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sivchari/crx/internal/browser"
+	"github.com/sivchari/crx/internal/config"
+	"github.com/sivchari/crx/internal/registry"
+)
+
+const (
+	idA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	idB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	idC = "cccccccccccccccccccccccccccccccc"
+
+	vimiumAddonID     = "vimium@example.org"
+	darkReaderAddonID = "addon@darkreader.org"
+)
+
+// vimium has a separate Edge Add-ons listing and a Firefox listing.
+var vimium = &registry.Package{
+	Name:        "vimium",
+	ID:          idA,
+	DisplayName: "Vimium",
+	EdgeAddons:  &registry.Listing{ID: idB},
+	Firefox:     &registry.FirefoxListing{AddonID: vimiumAddonID, Slug: "vimium"},
+}
+
+var darkReader = &registry.Package{
+	Name:        "dark-reader",
+	ID:          idC,
+	DisplayName: "Dark Reader",
+	Firefox:     &registry.FirefoxListing{AddonID: darkReaderAddonID, Slug: "darkreader"},
+}
+
+func TestGenerate(t *testing.T) {
+	const message = "Ask IT for approval."
+
+	tests := []struct {
+		name     string
+		cfg      *config.Config
+		packages []*registry.Package
+		blocked  []*registry.Package
+		want     Policies
+		// wantErr is a part of the expected error, or empty for none.
+		wantErr string
+	}{
+		{
+			name: "force install",
+			cfg: &config.Config{
+				Extensions: []config.Extension{{Name: "vimium"}},
+				Settings:   config.Settings{Mode: config.ModeForceInstall},
+			},
+			packages: []*registry.Package{vimium},
+			want: Policies{
+				registry.StoreChromeWebStore: {ExtensionInstallForcelist: []string{idA + ";" + registry.CRXUpdateURL}},
+			},
+		},
+		{
+			name: "normal install with options",
+			cfg: &config.Config{
+				Extensions: []config.Extension{{
+					Name:             "vimium",
+					ExtensionOptions: config.ExtensionOptions{ToolbarPin: config.ToolbarForcePinned},
+				}},
+				Settings: config.Settings{Mode: config.ModeNormalInstall},
+			},
+			packages: []*registry.Package{vimium},
+			want: Policies{
+				registry.StoreChromeWebStore: {ExtensionSettings: ExtensionSettings{idA: {
+					InstallationMode: InstallationNormalInstalled,
+					UpdateURL:        registry.CRXUpdateURL,
+					ToolbarPin:       config.ToolbarForcePinned,
+				}}},
+			},
+		},
+		{
+			name: "per-store listings",
+			cfg: &config.Config{
+				Extensions: []config.Extension{{Name: "vimium"}},
+				Settings: config.Settings{
+					Mode:     config.ModeForceInstall,
+					Browsers: []string{browser.Chrome, "brave", "edge", browser.Firefox},
+				},
+			},
+			packages: []*registry.Package{vimium},
+			want: Policies{
+				registry.StoreChromeWebStore: {ExtensionInstallForcelist: []string{idA + ";" + registry.CRXUpdateURL}},
+				registry.StoreEdgeAddons:     {ExtensionInstallForcelist: []string{idB + ";" + registry.EdgeUpdateURL}},
+				registry.StoreFirefox: {ExtensionSettings: ExtensionSettings{vimiumAddonID: {
+					InstallationMode: InstallationForceInstalled,
+					InstallURL:       vimium.Firefox.InstallURL(),
+				}}},
+			},
+		},
+		{
+			name: "Chrome Web Store listing used without an Edge listing",
+			cfg: &config.Config{
+				Extensions: []config.Extension{{Name: "dark-reader"}},
+				Settings:   config.Settings{Mode: config.ModeAllowed, Browsers: []string{"edge"}},
+			},
+			packages: []*registry.Package{darkReader},
+			want: Policies{
+				registry.StoreEdgeAddons: {ExtensionInstallAllowlist: []string{idC}},
+			},
+		},
+		{
+			name: "lockdown",
+			cfg: &config.Config{
+				Extensions: []config.Extension{{Name: "vimium"}},
+				Settings: config.Settings{
+					Mode:                  config.ModeForceInstall,
+					Lockdown:              true,
+					BlockedInstallMessage: message,
+					Browsers:              []string{browser.Chrome, browser.Firefox},
+				},
+			},
+			packages: []*registry.Package{vimium},
+			want: Policies{
+				registry.StoreChromeWebStore: {
+					ExtensionInstallForcelist: []string{idA + ";" + registry.CRXUpdateURL},
+					ExtensionInstallAllowlist: []string{idA},
+					ExtensionInstallBlocklist: []string{DefaultSettingsKey},
+					ExtensionSettings: ExtensionSettings{DefaultSettingsKey: {
+						InstallationMode:      InstallationBlocked,
+						BlockedInstallMessage: message,
+					}},
+				},
+				registry.StoreFirefox: {ExtensionSettings: ExtensionSettings{
+					vimiumAddonID: {
+						InstallationMode: InstallationForceInstalled,
+						InstallURL:       vimium.Firefox.InstallURL(),
+					},
+					DefaultSettingsKey: {
+						InstallationMode:      InstallationBlocked,
+						BlockedInstallMessage: message,
+					},
+				}},
+			},
+		},
+		{
+			name: "block list",
+			cfg: &config.Config{
+				Extensions: []config.Extension{{Name: "vimium"}},
+				Block:      []string{"dark-reader"},
+				Settings: config.Settings{
+					Mode:                  config.ModeAllowed,
+					BlockedInstallMessage: message,
+					Browsers:              []string{browser.Chrome, browser.Firefox},
+				},
+			},
+			packages: []*registry.Package{vimium},
+			blocked:  []*registry.Package{darkReader},
+			want: Policies{
+				registry.StoreChromeWebStore: {
+					ExtensionInstallAllowlist: []string{idA},
+					ExtensionInstallBlocklist: []string{idC},
+					ExtensionSettings: ExtensionSettings{idC: {
+						InstallationMode:      InstallationBlocked,
+						BlockedInstallMessage: message,
+					}},
+				},
+				registry.StoreFirefox: {ExtensionSettings: ExtensionSettings{
+					vimiumAddonID: {InstallationMode: InstallationAllowed},
+					darkReaderAddonID: {
+						InstallationMode:      InstallationBlocked,
+						BlockedInstallMessage: message,
+					},
+				}},
+			},
+		},
+		{
+			name: "configured and blocked",
+			cfg: &config.Config{
+				Extensions: []config.Extension{{Name: "dark-reader"}},
+				Block:      []string{idC},
+				Settings:   config.Settings{Mode: config.ModeForceInstall},
+			},
+			packages: []*registry.Package{darkReader},
+			blocked:  []*registry.Package{{Name: idC, ID: idC, DisplayName: idC}},
+			wantErr:  "extension dark-reader is configured with mode force_install but blocked",
+		},
+		{
+			name: "insecure update URL",
+			cfg: &config.Config{
+				Extensions: []config.Extension{{Name: "internal", ID: idA, UpdateURL: "http://example.com/update.xml"}},
+				Settings:   config.Settings{Mode: config.ModeForceInstall},
+			},
+			packages: []*registry.Package{{Name: "internal", ID: idA, UpdateURL: "http://example.com/update.xml"}},
+			wantErr:  "extension internal:",
+		},
+		{
+			name: "packages missing",
+			cfg: &config.Config{
+				Extensions: []config.Extension{{Name: "vimium"}, {Name: "dark-reader"}},
+				Settings:   config.Settings{Mode: config.ModeForceInstall},
+			},
+			packages: []*registry.Package{vimium},
+			wantErr:  "got 1 packages for 2 configured extensions",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewGenerator(tt.cfg, tt.packages, tt.blocked).Generate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Generate() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Generate() = %s, want %s", formatPolicies(got), formatPolicies(tt.want))
+			}
+		})
+	}
+}
+
+// formatPolicies formats policies as JSON for failure messages.
+func formatPolicies(policies Policies) string {
+	data, err := json.MarshalIndent(policies, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
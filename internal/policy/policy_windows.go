@@ -3,27 +3,44 @@
 package policy
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"golang.org/x/sys/windows/registry"
+
+	"github.com/sivchari/crx/internal/browser"
 )
 
 const (
-	chromePolicyKey = `SOFTWARE\Policies\Google\Chrome`
-	forcelistKey    = `ExtensionInstallForcelist`
-	allowlistKey    = `ExtensionInstallAllowlist`
-	blocklistKey    = `ExtensionInstallBlocklist`
+	forcelistKey  = `ExtensionInstallForcelist`
+	allowlistKey  = `ExtensionInstallAllowlist`
+	blocklistKey  = `ExtensionInstallBlocklist`
+	settingsValue = `ExtensionSettings`
 )
 
 // applyWindows applies the policy on Windows using registry.
-func (g *Generator) applyWindows(policy *Policy) error {
-	// Open or create the Chrome policy key
-	chromeKey, _, err := registry.CreateKey(registry.LOCAL_MACHINE, chromePolicyKey, registry.ALL_ACCESS)
+func (g *Generator) applyWindows(policies Policies, browsers []browser.Browser) error {
+	for _, b := range uniqueLocations(browsers, func(b browser.Browser) string { return b.WindowsPolicyKey }) {
+		if err := writeWindowsPolicy(b.WindowsPolicyKey, policies.For(b)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeWindowsPolicy writes the policy under the browser policy key.
+func writeWindowsPolicy(key string, policy *Policy) error {
+	// Open or create the browser policy key
+	chromeKey, _, err := registry.CreateKey(registry.LOCAL_MACHINE, key, registry.ALL_ACCESS)
 	if err != nil {
-		return fmt.Errorf("failed to open Chrome policy key (try running as Administrator): %w", err)
+		return fmt.Errorf("failed to open policy key %s (try running as Administrator): %w", key, err)
 	}
 	defer chromeKey.Close()
 
+	// Remove the values of the previous policy, so that the key holds
+	// exactly this policy.
+	clearWindowsPolicy(chromeKey)
+
 	// Apply ExtensionInstallForcelist
 	if len(policy.ExtensionInstallForcelist) > 0 {
 		if err := writeStringList(chromeKey, forcelistKey, policy.ExtensionInstallForcelist); err != nil {
@@ -45,6 +62,18 @@ func (g *Generator) applyWindows(policy *Policy) error {
 		}
 	}
 
+	// Apply ExtensionSettings. Dictionary policies are stored as a JSON
+	// string value on Windows.
+	if len(policy.ExtensionSettings) > 0 {
+		data, err := json.Marshal(policy.ExtensionSettings)
+		if err != nil {
+			return fmt.Errorf("failed to encode ExtensionSettings: %w", err)
+		}
+		if err := chromeKey.SetStringValue(settingsValue, string(data)); err != nil {
+			return fmt.Errorf("failed to write ExtensionSettings: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -72,18 +101,121 @@ func writeStringList(parentKey registry.Key, subkeyName string, values []string)
 	return nil
 }
 
-// RemoveWindowsPolicy removes the crx Chrome policy from Windows registry.
-func RemoveWindowsPolicy() error {
-	chromeKey, err := registry.OpenKey(registry.LOCAL_MACHINE, chromePolicyKey, registry.ALL_ACCESS)
+// readWindows reads the crx policy values under the browser policy key.
+func readWindows(key string) (*Policy, error) {
+	chromeKey, err := registry.OpenKey(registry.LOCAL_MACHINE, key, registry.READ)
+	if err == registry.ErrNotExist {
+		return &Policy{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Chrome policy key: %w", err)
+	}
+	defer chromeKey.Close()
+
+	policy := &Policy{}
+	if policy.ExtensionInstallForcelist, err = readStringList(chromeKey, forcelistKey); err != nil {
+		return nil, fmt.Errorf("failed to read ExtensionInstallForcelist: %w", err)
+	}
+	if policy.ExtensionInstallAllowlist, err = readStringList(chromeKey, allowlistKey); err != nil {
+		return nil, fmt.Errorf("failed to read ExtensionInstallAllowlist: %w", err)
+	}
+	if policy.ExtensionInstallBlocklist, err = readStringList(chromeKey, blocklistKey); err != nil {
+		return nil, fmt.Errorf("failed to read ExtensionInstallBlocklist: %w", err)
+	}
+
+	settings, _, err := chromeKey.GetStringValue(settingsValue)
+	switch {
+	case err == registry.ErrNotExist:
+	case err != nil:
+		return nil, fmt.Errorf("failed to read ExtensionSettings: %w", err)
+	default:
+		if err := json.Unmarshal([]byte(settings), &policy.ExtensionSettings); err != nil {
+			return nil, fmt.Errorf("failed to parse ExtensionSettings: %w", err)
+		}
+	}
+
+	return policy, nil
+}
+
+// readStringList reads the numbered values written by writeStringList.
+func readStringList(parentKey registry.Key, subkeyName string) ([]string, error) {
+	subkey, err := registry.OpenKey(parentKey, subkeyName, registry.READ)
+	if err == registry.ErrNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer subkey.Close()
+
+	var values []string
+	for i := 1; ; i++ {
+		value, _, err := subkey.GetStringValue(fmt.Sprintf("%d", i))
+		if err == registry.ErrNotExist {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// RemoveWindowsPolicy removes the crx policy of the browser from Windows registry.
+func RemoveWindowsPolicy(b browser.Browser) error {
+	chromeKey, err := registry.OpenKey(registry.LOCAL_MACHINE, b.WindowsPolicyKey, registry.ALL_ACCESS)
 	if err != nil {
-		return fmt.Errorf("failed to open Chrome policy key: %w", err)
+		return fmt.Errorf("failed to open %s policy key: %w", b.DisplayName, err)
 	}
 	defer chromeKey.Close()
 
-	// Delete policy subkeys
+	clearWindowsPolicy(chromeKey)
+
+	return nil
+}
+
+// clearWindowsPolicy deletes the policy values written by writeWindowsPolicy.
+func clearWindowsPolicy(chromeKey registry.Key) {
 	_ = registry.DeleteKey(chromeKey, forcelistKey)
 	_ = registry.DeleteKey(chromeKey, allowlistKey)
 	_ = registry.DeleteKey(chromeKey, blocklistKey)
+	_ = chromeKey.DeleteValue(settingsValue)
+}
+
+// readWindowsSnapshot returns the policy values under the key as JSON, or
+// nil if there are none.
+func readWindowsSnapshot(key string) ([]byte, error) {
+	policy, err := readWindows(key)
+	if err != nil {
+		return nil, err
+	}
+	if policy.empty() {
+		return nil, nil
+	}
+	return json.Marshal(policy)
+}
+
+// restoreWindows replaces the policy values under the key with a snapshot
+// from readWindowsSnapshot, removing them if data is nil.
+func restoreWindows(key string, data []byte) error {
+	if data != nil {
+		var policy Policy
+		if err := json.Unmarshal(data, &policy); err != nil {
+			return fmt.Errorf("failed to parse backup of %s: %w", key, err)
+		}
+		return writeWindowsPolicy(key, &policy)
+	}
+
+	chromeKey, err := registry.OpenKey(registry.LOCAL_MACHINE, key, registry.ALL_ACCESS)
+	if err == registry.ErrNotExist {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open policy key %s (try running as Administrator): %w", key, err)
+	}
+	defer chromeKey.Close()
 
+	clearWindowsPolicy(chromeKey)
 	return nil
 }
@@ -0,0 +1,65 @@
+package policy
+
+import (
+	"github.com/sivchari/crx/internal/config"
+)
+
+// ExtensionSettings is the ExtensionSettings policy, keyed by extension ID.
+type ExtensionSettings map[string]*ExtensionSetting
+
+// ExtensionSetting configures a single extension in ExtensionSettings.
+// See https://chromeenterprise.google/policies/#ExtensionSettings.
+type ExtensionSetting struct {
+	InstallationMode       string   `json:"installation_mode,omitempty"`
+	UpdateURL              string   `json:"update_url,omitempty"`
+	ToolbarPin             string   `json:"toolbar_pin,omitempty"`
+	BlockedPermissions     []string `json:"blocked_permissions,omitempty"`
+	AllowedPermissions     []string `json:"allowed_permissions,omitempty"`
+	RuntimeBlockedHosts    []string `json:"runtime_blocked_hosts,omitempty"`
+	RuntimeAllowedHosts    []string `json:"runtime_allowed_hosts,omitempty"`
+	MinimumVersionRequired string   `json:"minimum_version_required,omitempty"`
+	BlockedInstallMessage  string   `json:"blocked_install_message,omitempty"`
+
+	// InstallURL and DefaultArea are only read by Firefox.
+	InstallURL  string `json:"install_url,omitempty"`
+	DefaultArea string `json:"default_area,omitempty"`
+
+	// AllowedTypes and InstallSources are only valid in the default
+	// settings.
+	AllowedTypes   []string `json:"allowed_types,omitempty"`
+	InstallSources []string `json:"install_sources,omitempty"`
+}
+
+// DefaultSettingsKey is the ExtensionSettings key of the settings that apply
+// to every extension without its own entry.
+const DefaultSettingsKey = "*"
+
+// ExtensionSettings installation modes.
+const (
+	InstallationForceInstalled  = "force_installed"
+	InstallationNormalInstalled = "normal_installed"
+	InstallationAllowed         = "allowed"
+	InstallationBlocked         = "blocked"
+	InstallationRemoved         = "removed"
+)
+
+// newExtensionSetting returns the setting carrying the options of an
+// extension, without an installation mode.
+func newExtensionSetting(opts config.ExtensionOptions) *ExtensionSetting {
+	return &ExtensionSetting{
+		ToolbarPin:             opts.ToolbarPin,
+		BlockedPermissions:     opts.BlockedPermissions,
+		AllowedPermissions:     opts.AllowedPermissions,
+		RuntimeBlockedHosts:    opts.RuntimeBlockedHosts,
+		RuntimeAllowedHosts:    opts.RuntimeAllowedHosts,
+		MinimumVersionRequired: opts.MinimumVersionRequired,
+	}
+}
+
+// set stores setting for id, creating the map if needed.
+func (s *ExtensionSettings) set(id string, setting *ExtensionSetting) {
+	if *s == nil {
+		*s = make(ExtensionSettings)
+	}
+	(*s)[id] = setting
+}
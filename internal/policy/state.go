@@ -0,0 +1,243 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+
+	"github.com/sivchari/crx/internal/browser"
+	"github.com/sivchari/crx/internal/fsutil"
+	"github.com/sivchari/crx/internal/logger"
+)
+
+// appliedFileName is the name of the file in the state directory that
+// records the browsers crx last applied a policy to.
+const appliedFileName = "applied.json"
+
+// applied is the content of the applied file.
+type applied struct {
+	Browsers []string `json:"browsers"`
+}
+
+// linuxSystemStateDir is the state directory of root on Linux, where the
+// policies are applied with sudo and HOME depends on the sudo setup.
+const linuxSystemStateDir = "/var/lib/crx"
+
+// stateDir returns the directory crx keeps its state in: crx under
+// $XDG_STATE_HOME if set, linuxSystemStateDir for root on Linux, and
+// ~/.local/state/crx otherwise.
+func stateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "crx"), nil
+	}
+	if runtime.GOOS == "linux" && os.Geteuid() == 0 {
+		return linuxSystemStateDir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "crx"), nil
+}
+
+// loadApplied returns the names of the browsers crx last applied a policy
+// to.
+func loadApplied() ([]string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, appliedFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", appliedFileName, err)
+	}
+
+	var a applied
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", appliedFileName, err)
+	}
+	return a.Browsers, nil
+}
+
+// recordApplied records the browsers crx applied a policy to.
+func recordApplied(names []string) error {
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(applied{Browsers: names}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", appliedFileName, err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(filepath.Join(dir, appliedFileName), data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", appliedFileName, err)
+	}
+	return nil
+}
+
+// firefoxFileName is the name of the file in the state directory that
+// records the ExtensionSettings entries crx wrote to each policies.json.
+const firefoxFileName = "firefox.json"
+
+// loadFirefoxOwned returns the keys of the ExtensionSettings entries crx
+// wrote to the policies.json at path. Other entries belong to the admin.
+func loadFirefoxOwned(path string) ([]string, error) {
+	owned, err := loadFirefoxState()
+	if err != nil {
+		return nil, err
+	}
+	return owned[path], nil
+}
+
+// recordFirefoxOwned records keys as the ExtensionSettings entries crx
+// wrote to the policies.json at path.
+func recordFirefoxOwned(path string, keys []string) error {
+	owned, err := loadFirefoxState()
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		delete(owned, path)
+	} else {
+		owned[path] = keys
+	}
+
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(owned, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", firefoxFileName, err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := fsutil.WriteFileAtomic(filepath.Join(dir, firefoxFileName), data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", firefoxFileName, err)
+	}
+	return nil
+}
+
+// loadFirefoxState returns the keys of the ExtensionSettings entries crx
+// wrote, by policies.json path.
+func loadFirefoxState() (map[string][]string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return nil, err
+	}
+	owned := make(map[string][]string)
+	data, err := os.ReadFile(filepath.Join(dir, firefoxFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return owned, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", firefoxFileName, err)
+	}
+	if err := json.Unmarshal(data, &owned); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", firefoxFileName, err)
+	}
+	if owned == nil {
+		owned = make(map[string][]string)
+	}
+	return owned, nil
+}
+
+// Stale returns the browsers that are not among browsers, the configured
+// ones, but still have a policy written by crx: the browsers of earlier
+// applies, and on Linux any browser with a crx policy file. Browsers that
+// share their policy location with a configured browser are left out.
+func Stale(browsers []browser.Browser) ([]browser.Browser, error) {
+	names, err := loadApplied()
+	if err != nil {
+		return nil, err
+	}
+	if runtime.GOOS == "linux" {
+		for _, name := range browser.Names() {
+			b, _ := browser.Lookup(name)
+			if b.IsFirefox() {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(b.LinuxPolicyDir, policyFileName)); err == nil {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var stale []browser.Browser
+	for _, name := range names {
+		b, err := browser.Lookup(name)
+		if err != nil {
+			logger.Debug("skipping unknown browser of an earlier apply", "browser", name)
+			continue
+		}
+		location := Location(b)
+		sameLocation := func(o browser.Browser) bool { return Location(o) == location }
+		if slices.ContainsFunc(browsers, sameLocation) || slices.ContainsFunc(stale, sameLocation) {
+			continue
+		}
+
+		installed, err := ReadInstalled(b)
+		if err != nil {
+			return nil, err
+		}
+		if installed.empty() {
+			continue
+		}
+		stale = append(stale, b)
+	}
+	return stale, nil
+}
+
+// removeStale removes the crx policy of the stale browsers. On macOS the
+// configuration profile written for browsers no longer configures them,
+// so there is nothing to remove unless it is written for Firefox alone.
+func removeStale(stale, browsers []browser.Browser) error {
+	for _, b := range stale {
+		if b.IsFirefox() {
+			if err := applyFirefox(&Policy{}, b); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch runtime.GOOS {
+		case "darwin":
+			if !slices.ContainsFunc(browsers, func(b browser.Browser) bool { return !b.IsFirefox() }) {
+				logger.Warn("the installed configuration profile still configures a browser that is no longer configured; remove it in System Settings",
+					"browser", b.Name, "profile", "com.crx.chrome.extensions")
+			}
+		case "linux":
+			path := filepath.Join(b.LinuxPolicyDir, policyFileName)
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+		case "windows":
+			if err := RemoveWindowsPolicy(b); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported OS: %s", runtime.GOOS)
+		}
+	}
+	return nil
+}
+
+// browserNames returns the names of browsers.
+func browserNames(browsers []browser.Browser) []string {
+	names := make([]string, len(browsers))
+	for i, b := range browsers {
+		names[i] = b.Name
+	}
+	return names
+}
@@ -0,0 +1,147 @@
+// Package profile inspects the extensions installed in local browser
+// profiles.
+package profile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/sivchari/crx/internal/browser"
+	"github.com/sivchari/crx/internal/registry"
+)
+
+// Extension is an extension installed in a browser profile.
+type Extension struct {
+	ID      string
+	Name    string
+	Version string
+	// Browser is the name of the browser, e.g. "chrome".
+	Browser string
+	// Profile is the profile directory name, e.g. "Default" or "Profile 1".
+	Profile string
+}
+
+// Installed returns the extensions installed in every profile of the
+// current user in the given browsers, ordered by browser, profile and ID.
+func Installed(browsers []browser.Browser) ([]Extension, error) {
+	var extensions []Extension
+	for _, b := range browsers {
+		dir, err := b.UserDataDir()
+		if err != nil {
+			return nil, err
+		}
+		if dir == "" {
+			continue
+		}
+		found, err := InstalledIn(dir)
+		if err != nil {
+			return nil, err
+		}
+		for i := range found {
+			found[i].Browser = b.Name
+		}
+		extensions = append(extensions, found...)
+	}
+	return extensions, nil
+}
+
+// InstalledIn returns the extensions installed in the profiles under the
+// user data directory dir. A missing directory yields no extensions.
+func InstalledIn(dir string) ([]Extension, error) {
+	// Extensions live in <profile>/Extensions/<id>/<version>/.
+	matches, err := filepath.Glob(filepath.Join(dir, "*", "Extensions", "*"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(matches)
+
+	var extensions []Extension
+	for _, path := range matches {
+		id := filepath.Base(path)
+		if registry.ValidateID(id) != nil {
+			// Skips Temp and other non-extension directories.
+			continue
+		}
+		ext, ok := readExtension(path)
+		if !ok {
+			continue
+		}
+		ext.ID = id
+		ext.Profile = filepath.Base(filepath.Dir(filepath.Dir(path)))
+		extensions = append(extensions, ext)
+	}
+	return extensions, nil
+}
+
+// manifest holds the manifest.json fields used to describe an extension.
+type manifest struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	DefaultLocale string `json:"default_locale"`
+}
+
+// readExtension reads the manifest of the installed version in dir. Chrome
+// keeps a single version directory except briefly during updates, so the
+// last one is used.
+func readExtension(dir string) (Extension, bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return Extension{}, false
+	}
+
+	var versionDir string
+	for _, e := range entries {
+		if e.IsDir() {
+			versionDir = filepath.Join(dir, e.Name())
+		}
+	}
+	if versionDir == "" {
+		return Extension{}, false
+	}
+
+	data, err := os.ReadFile(filepath.Join(versionDir, "manifest.json"))
+	if err != nil {
+		return Extension{}, false
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Extension{}, false
+	}
+
+	return Extension{
+		Name:    localize(versionDir, m.DefaultLocale, m.Name),
+		Version: m.Version,
+	}, true
+}
+
+// localize resolves a "__MSG_key__" manifest string from the default
+// locale of the extension. Other strings are returned as they are.
+func localize(dir, locale, s string) string {
+	key, ok := strings.CutPrefix(s, "__MSG_")
+	if !ok || locale == "" {
+		return s
+	}
+	key = strings.TrimSuffix(key, "__")
+
+	data, err := os.ReadFile(filepath.Join(dir, "_locales", locale, "messages.json"))
+	if err != nil {
+		return s
+	}
+	var messages map[string]struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return s
+	}
+
+	// Message keys are case-insensitive.
+	for k, msg := range messages {
+		if strings.EqualFold(k, key) {
+			return msg.Message
+		}
+	}
+	return s
+}
@@ -0,0 +1,58 @@
+package registry
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GitHubToken returns the token used to access GitHub registries.
+// It is looked up, in order, from the environment variable named by
+// tokenEnv, from GITHUB_TOKEN, and from the GitHub CLI configuration.
+// An empty string means requests are unauthenticated.
+func GitHubToken(tokenEnv string) string {
+	if tokenEnv != "" {
+		if token := os.Getenv(tokenEnv); token != "" {
+			return token
+		}
+	}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		return token
+	}
+	return ghToken()
+}
+
+// ghToken reads the github.com token stored by the GitHub CLI, either in
+// hosts.yml or, for newer versions, in the system keyring via "gh auth token".
+// The token is the same for every registry, so it is only looked up once.
+var ghToken = sync.OnceValue(func() string {
+	dir := os.Getenv("GH_CONFIG_DIR")
+	if dir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config", "gh")
+		}
+	}
+	if dir != "" {
+		if data, err := os.ReadFile(filepath.Join(dir, "hosts.yml")); err == nil {
+			var hosts map[string]struct {
+				OAuthToken string `yaml:"oauth_token"`
+			}
+			if yaml.Unmarshal(data, &hosts) == nil && hosts["github.com"].OAuthToken != "" {
+				return hosts["github.com"].OAuthToken
+			}
+		}
+	}
+
+	if _, err := exec.LookPath("gh"); err != nil {
+		return ""
+	}
+	out, err := exec.Command("gh", "auth", "token", "--hostname", "github.com").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+})
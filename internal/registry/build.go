@@ -0,0 +1,148 @@
+package registry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sivchari/crx/internal/fsutil"
+	"github.com/sivchari/crx/internal/logger"
+)
+
+// LoadPackageFiles loads every package file in the pkgs directory of the
+// local registry at dir, sorted by name.
+func LoadPackageFiles(dir string) ([]*Package, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, PackagesDir, "*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list package files: %w", err)
+	}
+
+	packages := make([]*Package, 0, len(paths))
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".yaml")
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read package %s: %w", name, err)
+		}
+		pkg, err := parsePackage(name, data)
+		if err != nil {
+			return nil, err
+		}
+		if pkg.Name != name {
+			return nil, fmt.Errorf("package %s: name %q does not match file name", name, pkg.Name)
+		}
+		packages = append(packages, pkg)
+	}
+
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Name < packages[j].Name
+	})
+
+	return packages, nil
+}
+
+// BuildIndex generates the bundled index.yaml for the local registry at dir
+// from its package files, and upgrades registry.yaml to version 2 with the
+// sorted package list so that version 1 clients keep working. registry.yaml
+// also maps every alias to its package. Every package must be valid, since
+// clients trust the index. registry.yaml is rewritten from the package
+// files, so its comments are not kept and names listed without a package
+// file are dropped with a warning.
+func BuildIndex(dir string) (*Index, error) {
+	packages, err := LoadPackageFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, pkg := range packages {
+		errs = append(errs, pkg.Validate())
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	idx := &Index{
+		Version:  RegistryV2,
+		Packages: packages,
+		Digests:  make(map[string]string, len(packages)),
+	}
+	reg := &Registry{
+		Version:  RegistryV2,
+		Packages: make([]string, 0, len(packages)),
+	}
+	for _, pkg := range packages {
+		reg.Packages = append(reg.Packages, pkg.Name)
+		idx.Digests[pkg.Name] = pkg.Digest
+		for _, alias := range pkg.Aliases {
+			if reg.Aliases == nil {
+				reg.Aliases = make(map[string]string)
+			}
+			reg.Aliases[alias] = pkg.Name
+		}
+	}
+
+	warnDropped(dir, reg.Packages)
+
+	if err := writeYAML(filepath.Join(dir, IndexFile), idx); err != nil {
+		return nil, err
+	}
+	if err := writeYAML(filepath.Join(dir, RegistryFile), reg); err != nil {
+		return nil, err
+	}
+
+	return idx, nil
+}
+
+// warnDropped warns about the names listed in the registry.yaml at dir that
+// are not among names, the packages that have a package file.
+func warnDropped(dir string, names []string) {
+	data, err := os.ReadFile(filepath.Join(dir, RegistryFile))
+	if err != nil {
+		return
+	}
+	reg, err := parseRegistry(data)
+	if err != nil {
+		logger.Warn("replacing a registry.yaml that cannot be parsed", "error", err)
+		return
+	}
+	for _, name := range reg.Packages {
+		if !slices.Contains(names, name) {
+			logger.Warn("package listed in registry.yaml has no package file, dropping it", "name", name)
+		}
+	}
+}
+
+// marshalYAML encodes v as YAML with two-space indentation.
+func marshalYAML(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeYAML writes v to path as YAML with two-space indentation.
+func writeYAML(path string, v any) error {
+	data, err := marshalYAML(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", filepath.Base(path), err)
+	}
+
+	if err := fsutil.WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
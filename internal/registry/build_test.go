@@ -0,0 +1,93 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildIndex(t *testing.T) {
+	tests := []struct {
+		name     string
+		registry string
+		// packages are the package files, by name.
+		packages map[string]string
+		want     []string
+		// wantErr are parts of the expected error, or empty for none.
+		wantErr []string
+	}{
+		{
+			name:     "valid packages",
+			registry: "version: 1\npackages:\n  - vimium\n",
+			packages: map[string]string{
+				"vimium":      testPackage,
+				"dark-reader": "name: dark-reader\nid: eimadpbcbfnmbkopoojfekhnkhdbieeh\ndisplay_name: Dark Reader\n",
+			},
+			want: []string{"dark-reader", "vimium"},
+		},
+		{
+			name:     "name without a package file dropped",
+			registry: "version: 1\npackages:\n  - vimium\n  - removed\n",
+			packages: map[string]string{"vimium": testPackage},
+			want:     []string{"vimium"},
+		},
+		{
+			name:     "invalid ID",
+			registry: "version: 1\npackages: []\n",
+			packages: map[string]string{"bad": "name: bad\nid: not-an-id\ndisplay_name: Bad\n"},
+			wantErr:  []string{"not-an-id"},
+		},
+		{
+			name:     "every invalid package reported",
+			registry: "version: 1\npackages: []\n",
+			packages: map[string]string{
+				"bad":    "name: bad\nid: not-an-id\ndisplay_name: Bad\n",
+				"vimium": testPackage + "homepage: ftp://example.com\n",
+			},
+			wantErr: []string{"not-an-id", "package vimium: homepage"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFile(t, filepath.Join(dir, RegistryFile), tt.registry)
+			for name, content := range tt.packages {
+				writeTestFile(t, filepath.Join(dir, PackagesDir, name+".yaml"), content)
+			}
+
+			_, err := BuildIndex(dir)
+			if len(tt.wantErr) > 0 {
+				if err == nil {
+					t.Fatal("BuildIndex() error = nil, want an error")
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("BuildIndex() error = %v, want it to contain %q", err, want)
+					}
+				}
+				if _, err := os.Stat(filepath.Join(dir, IndexFile)); !os.IsNotExist(err) {
+					t.Errorf("%s written despite invalid packages", IndexFile)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BuildIndex() error = %v", err)
+			}
+
+			data, err := os.ReadFile(filepath.Join(dir, RegistryFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			reg, err := parseRegistry(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reg.Version != RegistryV2 || !reflect.DeepEqual(reg.Packages, tt.want) {
+				t.Errorf("registry.yaml = version %d, packages %v, want version %d, packages %v", reg.Version, reg.Packages, RegistryV2, tt.want)
+			}
+		})
+	}
+}
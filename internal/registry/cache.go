@@ -0,0 +1,171 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sivchari/crx/internal/fsutil"
+)
+
+// Cache provides simple in-memory caching. It is safe for concurrent use.
+type Cache struct {
+	mu   sync.Mutex
+	data map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	data      []byte
+	timestamp time.Time
+}
+
+// NewCache creates a new Cache.
+func NewCache() *Cache {
+	return &Cache{
+		data: make(map[string]cacheEntry),
+	}
+}
+
+// Get retrieves data from cache.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.data[key]
+	if !ok {
+		return nil, false
+	}
+
+	// Cache expires after 5 minutes
+	if time.Since(entry.timestamp) > 5*time.Minute {
+		delete(c.data, key)
+		return nil, false
+	}
+
+	return entry.data, true
+}
+
+// Set stores data in cache.
+func (c *Cache) Set(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.data[key] = cacheEntry{
+		data:      data,
+		timestamp: time.Now(),
+	}
+}
+
+// CacheDir returns the cache directory path.
+func CacheDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cache", "crx"), nil
+}
+
+// DiskCache persists fetched registry files on disk together with the
+// validators needed to revalidate them with conditional requests.
+type DiskCache struct {
+	dir string
+}
+
+// DiskEntry is a registry file stored in the disk cache.
+type DiskEntry struct {
+	Data         []byte    `json:"-"`
+	Key          string    `json:"key"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	// NotFound records that the file did not exist when last fetched.
+	NotFound bool `json:"not_found,omitempty"`
+}
+
+// NewDiskCache creates a new DiskCache rooted at dir.
+func NewDiskCache(dir string) *DiskCache {
+	return &DiskCache{
+		dir: dir,
+	}
+}
+
+// NewDefaultDiskCache creates a DiskCache under CacheDir.
+// It returns nil if the cache directory cannot be determined.
+func NewDefaultDiskCache() *DiskCache {
+	dir, err := CacheDir()
+	if err != nil {
+		return nil
+	}
+	return NewDiskCache(filepath.Join(dir, "registry"))
+}
+
+// Load retrieves an entry from the disk cache.
+func (c *DiskCache) Load(key string) (*DiskEntry, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	dataPath, metaPath := c.paths(key)
+
+	meta, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, false
+	}
+	var entry DiskEntry
+	if err := json.Unmarshal(meta, &entry); err != nil || entry.Key != key {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(dataPath)
+	if err != nil {
+		return nil, false
+	}
+	entry.Data = data
+
+	return &entry, true
+}
+
+// Store saves an entry to the disk cache.
+func (c *DiskCache) Store(key string, entry *DiskEntry) error {
+	if c == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	entry.Key = key
+	meta, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+
+	dataPath, metaPath := c.paths(key)
+	if err := fsutil.WriteFileAtomic(dataPath, entry.Data, 0644); err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(metaPath, meta, 0644)
+}
+
+// Delete removes an entry from the disk cache.
+func (c *DiskCache) Delete(key string) {
+	if c == nil {
+		return
+	}
+	dataPath, metaPath := c.paths(key)
+	_ = os.Remove(metaPath)
+	_ = os.Remove(dataPath)
+}
+
+// paths returns the data and metadata file paths for key.
+func (c *DiskCache) paths(key string) (string, string) {
+	sum := sha256.Sum256([]byte(key))
+	base := filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+	return base, base + ".json"
+}
@@ -0,0 +1,246 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sivchari/crx/internal/logger"
+)
+
+// Fetcher fetches registry data from a registry source.
+type Fetcher interface {
+	// FetchRegistry fetches the registry index.
+	FetchRegistry() (*Registry, error)
+	// FetchPackage fetches a package definition by name.
+	FetchPackage(name string) (*Package, error)
+	// FetchAllPackages fetches all packages listed in the registry.
+	FetchAllPackages() ([]*Package, error)
+	// Search searches packages by name or tag.
+	Search(query string) ([]*Package, error)
+}
+
+// Revisioner is implemented by fetchers that can report the revision of
+// the registry content they serve.
+type Revisioner interface {
+	// Revision returns the revision, such as a commit SHA.
+	Revision() (string, error)
+}
+
+// Option configures a Fetcher.
+type Option func(*options)
+
+type options struct {
+	offline    bool
+	publicKeys []string
+	token      string
+}
+
+// WithOffline makes the fetcher serve files from the disk cache only.
+// It has no effect on local registries.
+func WithOffline(offline bool) Option {
+	return func(o *options) {
+		o.offline = offline
+	}
+}
+
+// WithPublicKeys makes the fetcher verify registry content against a
+// manifest signed by one of the given base64-encoded ed25519 public keys.
+func WithPublicKeys(keys ...string) Option {
+	return func(o *options) {
+		o.publicKeys = keys
+	}
+}
+
+// WithToken authenticates requests to GitHub with token, which allows
+// fetching from private repositories. It has no effect on local registries.
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Registry file layout.
+const (
+	RegistryFile = "registry.yaml"
+	IndexFile    = "index.yaml"
+	PackagesDir  = "pkgs"
+)
+
+// fileFetcher is a Fetcher that can read raw files from the registry root.
+type fileFetcher interface {
+	Fetcher
+	read(path string) ([]byte, error)
+}
+
+// packagePath returns the path of a package file relative to the registry root.
+func packagePath(name string) string {
+	return fmt.Sprintf("%s/%s.yaml", PackagesDir, name)
+}
+
+func parseRegistry(data []byte) (*Registry, error) {
+	var reg Registry
+	if err := yaml.Unmarshal(data, &reg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", RegistryFile, err)
+	}
+	return &reg, nil
+}
+
+func parsePackage(name string, data []byte) (*Package, error) {
+	var pkg Package
+	if err := yaml.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse package %s: %w", name, err)
+	}
+	pkg.Digest = Digest(data)
+	return &pkg, nil
+}
+
+func parseIndex(data []byte) (*Index, error) {
+	var idx Index
+	if err := yaml.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", IndexFile, err)
+	}
+	if idx.Version != RegistryV2 {
+		return nil, fmt.Errorf("unsupported %s version: %d", IndexFile, idx.Version)
+	}
+	return &idx, nil
+}
+
+// bundledIndex lazily loads the bundled index of a version 2 registry.
+// A fetcher consults it before falling back to per-package files.
+type bundledIndex struct {
+	once     sync.Once
+	packages []*Package
+	byName   map[string]*Package
+	err      error
+}
+
+func (b *bundledIndex) load(f fileFetcher) error {
+	b.once.Do(func() {
+		// Errors here are not fatal: the per-package files are tried next
+		// and report their own errors.
+		reg, err := f.FetchRegistry()
+		if err != nil {
+			logger.Debug("failed to fetch registry index, using package files", "error", err)
+			return
+		}
+		if reg.Version < RegistryV2 {
+			return
+		}
+
+		data, err := f.read(IndexFile)
+		if errors.Is(err, ErrNotFound) {
+			logger.Debug("registry has no bundled index, using package files", "version", reg.Version)
+			return
+		}
+		if err != nil {
+			b.err = fmt.Errorf("failed to fetch %s: %w", IndexFile, err)
+			return
+		}
+
+		idx, err := parseIndex(data)
+		if err != nil {
+			b.err = err
+			return
+		}
+		b.packages = idx.Packages
+		b.byName = make(map[string]*Package, len(idx.Packages))
+		for _, pkg := range idx.Packages {
+			pkg.Digest = idx.Digests[pkg.Name]
+			b.byName[pkg.Name] = pkg
+		}
+	})
+	return b.err
+}
+
+// lookup returns the named package from the bundled index. The boolean
+// reports whether the index was consulted at all.
+func (b *bundledIndex) lookup(f fileFetcher, name string) (*Package, bool, error) {
+	if err := b.load(f); err != nil {
+		return nil, true, err
+	}
+	if b.byName == nil {
+		return nil, false, nil
+	}
+	pkg, ok := b.byName[name]
+	if !ok {
+		return nil, true, fmt.Errorf("package %s: %w", name, ErrNotFound)
+	}
+	cp := *pkg
+	return &cp, true, nil
+}
+
+// all returns every package from the bundled index. The boolean reports
+// whether the registry has a bundled index.
+func (b *bundledIndex) all(f fileFetcher) ([]*Package, bool, error) {
+	if err := b.load(f); err != nil {
+		return nil, true, err
+	}
+	if b.byName == nil {
+		return nil, false, nil
+	}
+	packages := make([]*Package, 0, len(b.packages))
+	for _, pkg := range b.packages {
+		cp := *pkg
+		packages = append(packages, &cp)
+	}
+	return packages, true, nil
+}
+
+// fetchAll fetches every package listed in the registry using f.
+func fetchAll(f Fetcher) ([]*Package, error) {
+	reg, err := f.FetchRegistry()
+	if err != nil {
+		return nil, err
+	}
+
+	return FetchPackages(f, reg.Packages)
+}
+
+// fetchConcurrency is the maximum number of packages fetched at once.
+const fetchConcurrency = 8
+
+// FetchPackages fetches the named packages concurrently using f.
+// The result keeps the order of names. If any package fails, the returned
+// error joins the errors of every failing package.
+func FetchPackages(f Fetcher, names []string) ([]*Package, error) {
+	packages := make([]*Package, len(names))
+	errs := make([]error, len(names))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, fetchConcurrency)
+	for i, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			packages[i], errs[i] = f.FetchPackage(name)
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return packages, nil
+}
+
+// search returns the packages from f that match query, best matches first.
+func search(f Fetcher, query string) ([]*Package, error) {
+	packages, err := f.FetchAllPackages()
+	if err != nil {
+		return nil, err
+	}
+	return Rank(packages, query), nil
+}
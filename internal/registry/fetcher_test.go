@@ -0,0 +1,131 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeFetcher serves the packages named in packages and fails for names in
+// errs. It records the names it was asked for.
+type fakeFetcher struct {
+	packages []string
+	errs     map[string]error
+
+	mu        sync.Mutex
+	requested []string
+}
+
+func (f *fakeFetcher) FetchRegistry() (*Registry, error) {
+	return &Registry{Version: 1, Packages: f.packages}, nil
+}
+
+func (f *fakeFetcher) FetchPackage(name string) (*Package, error) {
+	f.mu.Lock()
+	f.requested = append(f.requested, name)
+	f.mu.Unlock()
+
+	if err, ok := f.errs[name]; ok {
+		return nil, err
+	}
+	for _, p := range f.packages {
+		if p == name {
+			return &Package{Name: name}, nil
+		}
+	}
+	return nil, fmt.Errorf("package %s: %w", name, ErrNotFound)
+}
+
+func (f *fakeFetcher) FetchAllPackages() ([]*Package, error) {
+	return fetchAll(f)
+}
+
+func (f *fakeFetcher) Search(query string) ([]*Package, error) {
+	return search(f, query)
+}
+
+func TestFetchPackages(t *testing.T) {
+	var names []string
+	for i := range 3 * fetchConcurrency {
+		names = append(names, fmt.Sprintf("pkg-%d", i))
+	}
+	errBroken := errors.New("broken package file")
+
+	tests := []struct {
+		name  string
+		names []string
+		errs  map[string]error
+		// wantErr are parts of the expected error, or empty for none.
+		wantErr []string
+		// wantIs are errors the error must wrap.
+		wantIs []error
+	}{
+		{
+			name:  "all found, in order",
+			names: names,
+		},
+		{
+			name:  "none",
+			names: nil,
+		},
+		{
+			name:    "one missing",
+			names:   append(slices.Clone(names), "missing"),
+			wantErr: []string{"package missing: not found"},
+			wantIs:  []error{ErrNotFound},
+		},
+		{
+			name:    "every failure reported",
+			names:   append([]string{"missing"}, names...),
+			errs:    map[string]error{"pkg-1": errBroken, "pkg-20": errBroken},
+			wantErr: []string{"package missing: not found", "broken package file"},
+			wantIs:  []error{ErrNotFound, errBroken},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeFetcher{packages: names, errs: tt.errs}
+			got, err := FetchPackages(f, tt.names)
+
+			// Every package is fetched, even after a failure.
+			if len(f.requested) != len(tt.names) {
+				t.Errorf("fetched %d packages, want %d", len(f.requested), len(tt.names))
+			}
+
+			if len(tt.wantErr) > 0 {
+				if err == nil {
+					t.Fatal("FetchPackages() error = nil, want an error")
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("FetchPackages() error = %v, want it to contain %q", err, want)
+					}
+				}
+				for _, want := range tt.wantIs {
+					if !errors.Is(err, want) {
+						t.Errorf("FetchPackages() error = %v, want it to wrap %v", err, want)
+					}
+				}
+				if got != nil {
+					t.Errorf("FetchPackages() = %v, want nil on error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchPackages() error = %v", err)
+			}
+			gotNames := make([]string, len(got))
+			for i, pkg := range got {
+				gotNames[i] = pkg.Name
+			}
+			if len(tt.names) > 0 && !reflect.DeepEqual(gotNames, tt.names) {
+				t.Errorf("FetchPackages() = %v, want %v", gotNames, tt.names)
+			}
+		})
+	}
+}
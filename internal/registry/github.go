@@ -1,212 +1,335 @@
 package registry
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
-	"path/filepath"
+	neturl "net/url"
+	"strings"
 	"time"
 
-	"gopkg.in/yaml.v3"
+	"github.com/sivchari/crx/internal/logger"
+)
+
+var (
+	// ErrNotFound is returned when a registry file does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrNotCached is returned in offline mode when a registry file has
+	// never been fetched.
+	ErrNotCached = errors.New("not available offline")
+)
+
+// AccessError is returned when a GitHub repository cannot be read: it does
+// not exist, it is private, or the token lacks access to it.
+type AccessError struct {
+	Repo string
+	// Authenticated reports whether the request carried a token.
+	Authenticated bool
+}
+
+func (e *AccessError) Error() string {
+	if !e.Authenticated {
+		return fmt.Sprintf("repository %s does not exist or is private; set GITHUB_TOKEN or token_env to access private registries", e.Repo)
+	}
+	return fmt.Sprintf("repository %s is private or token lacks access", e.Repo)
+}
+
+const (
+	rawBaseURL = "https://raw.githubusercontent.com"
+	apiBaseURL = "https://api.github.com"
 )
 
 // GitHubFetcher fetches registry data from GitHub.
 type GitHubFetcher struct {
-	repo   string // e.g., "user/crx-registry"
-	ref    string // e.g., "main"
-	client *http.Client
-	cache  *Cache
+	repo    string // e.g., "user/crx-registry"
+	ref     string // e.g., "main"
+	baseURL string
+	apiURL  string
+	client  *http.Client
+	cache   *Cache
+	disk    *DiskCache
+	offline bool
+	index   bundledIndex
+	verify  *verifier
+	token   string
 }
 
 // NewGitHubFetcher creates a new GitHubFetcher.
-func NewGitHubFetcher(repo, ref string) *GitHubFetcher {
+func NewGitHubFetcher(repo, ref string, opts ...Option) *GitHubFetcher {
 	if ref == "" {
 		ref = "main"
 	}
+	o := newOptions(opts)
 	return &GitHubFetcher{
-		repo: repo,
-		ref:  ref,
+		repo:    repo,
+		ref:     ref,
+		baseURL: rawBaseURL,
+		apiURL:  apiBaseURL,
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		cache: NewCache(),
+		cache:   NewCache(),
+		disk:    NewDefaultDiskCache(),
+		offline: o.offline,
+		verify:  newVerifier(o.publicKeys),
+		token:   o.token,
 	}
 }
 
 // FetchRegistry fetches the registry index.
 func (f *GitHubFetcher) FetchRegistry() (*Registry, error) {
-	url := f.rawURL("registry.yaml")
-
-	data, err := f.fetch(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch registry.yaml: %w", err)
+	data, err := f.read(RegistryFile)
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("failed to fetch %s: %w", RegistryFile, f.noAccess())
 	}
-
-	var reg Registry
-	if err := yaml.Unmarshal(data, &reg); err != nil {
-		return nil, fmt.Errorf("failed to parse registry.yaml: %w", err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", RegistryFile, err)
 	}
-
-	return &reg, nil
+	return parseRegistry(data)
 }
 
 // FetchPackage fetches a package definition.
+// The bundled index is used when the registry publishes one.
 func (f *GitHubFetcher) FetchPackage(name string) (*Package, error) {
-	url := f.rawURL(fmt.Sprintf("pkgs/%s.yaml", name))
+	// The name becomes part of a URL path.
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	if pkg, ok, err := f.index.lookup(f, name); ok {
+		return pkg, err
+	}
 
-	data, err := f.fetch(url)
+	data, err := f.read(packagePath(name))
+	if errors.Is(err, ErrNotFound) {
+		// A missing registry.yaml means the repository itself could not be
+		// read, not that it lacks the package.
+		if _, rerr := f.read(RegistryFile); errors.Is(rerr, ErrNotFound) {
+			return nil, fmt.Errorf("failed to fetch package %s: %w", name, f.noAccess())
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch package %s: %w", name, err)
 	}
-
-	var pkg Package
-	if err := yaml.Unmarshal(data, &pkg); err != nil {
-		return nil, fmt.Errorf("failed to parse package %s: %w", name, err)
-	}
-
-	return &pkg, nil
+	return parsePackage(name, data)
 }
 
 // FetchAllPackages fetches all packages listed in the registry.
+// The bundled index is used when the registry publishes one.
 func (f *GitHubFetcher) FetchAllPackages() ([]*Package, error) {
-	reg, err := f.FetchRegistry()
-	if err != nil {
-		return nil, err
+	if packages, ok, err := f.index.all(f); ok {
+		return packages, err
 	}
-
-	packages := make([]*Package, 0, len(reg.Packages))
-	for _, name := range reg.Packages {
-		pkg, err := f.FetchPackage(name)
-		if err != nil {
-			return nil, err
-		}
-		packages = append(packages, pkg)
-	}
-
-	return packages, nil
+	return fetchAll(f)
 }
 
 // Search searches packages by name or tag.
 func (f *GitHubFetcher) Search(query string) ([]*Package, error) {
-	packages, err := f.FetchAllPackages()
-	if err != nil {
-		return nil, err
-	}
-
-	var results []*Package
-	for _, pkg := range packages {
-		if matchesQuery(pkg, query) {
-			results = append(results, pkg)
-		}
-	}
-
-	return results, nil
+	return search(f, query)
 }
 
-func (f *GitHubFetcher) rawURL(path string) string {
-	return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s", f.repo, f.ref, path)
-}
+// Revision returns the commit SHA that the fetcher's ref points to.
+func (f *GitHubFetcher) Revision() (string, error) {
+	if f.offline {
+		return "", fmt.Errorf("%w: cannot resolve %s@%s", ErrNotCached, f.repo, f.ref)
+	}
 
-func (f *GitHubFetcher) fetch(url string) ([]byte, error) {
-	// Check cache first
-	if data, ok := f.cache.Get(url); ok {
-		return data, nil
+	url := fmt.Sprintf("%s/repos/%s/commits/%s", f.apiURL, f.repo, f.ref)
+	req, err := f.newRequest(url, "application/vnd.github.sha")
+	if err != nil {
+		return "", err
 	}
 
-	resp, err := f.client.Get(url)
+	resp, err := f.client.Do(req)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusTooManyRequests:
+		return "", fmt.Errorf("failed to resolve %s@%s: %w", f.repo, f.ref, f.accessError(resp))
+	default:
+		return "", fmt.Errorf("failed to resolve %s@%s: HTTP %d: %s", f.repo, f.ref, resp.StatusCode, resp.Status)
 	}
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
+	return strings.TrimSpace(string(data)), nil
+}
 
-	// Store in cache
-	f.cache.Set(url, data)
-
+func (f *GitHubFetcher) read(path string) ([]byte, error) {
+	data, err := f.readRaw(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.verify.check(f.readRaw, path, data); err != nil {
+		return nil, err
+	}
 	return data, nil
 }
 
-// Cache provides simple in-memory caching.
-type Cache struct {
-	data map[string]cacheEntry
+func (f *GitHubFetcher) readRaw(path string) ([]byte, error) {
+	return f.fetch(f.cacheKey(path), f.fileURL(path))
 }
 
-type cacheEntry struct {
-	data      []byte
-	timestamp time.Time
+// cacheKey identifies a registry file independently of how it is fetched,
+// so cached copies are shared between authenticated and anonymous runs.
+func (f *GitHubFetcher) cacheKey(path string) string {
+	return fmt.Sprintf("github.com/%s@%s/%s", f.repo, f.ref, path)
 }
 
-// NewCache creates a new Cache.
-func NewCache() *Cache {
-	return &Cache{
-		data: make(map[string]cacheEntry),
+// fileURL returns the URL of a registry file. Authenticated fetchers use
+// the contents API, since raw.githubusercontent.com does not serve private
+// repositories to API tokens.
+func (f *GitHubFetcher) fileURL(path string) string {
+	if f.token != "" {
+		return fmt.Sprintf("%s/repos/%s/contents/%s?ref=%s", f.apiURL, f.repo, path, neturl.QueryEscape(f.ref))
 	}
+	return fmt.Sprintf("%s/%s/%s/%s", f.baseURL, f.repo, f.ref, path)
 }
 
-// Get retrieves data from cache.
-func (c *Cache) Get(key string) ([]byte, bool) {
-	entry, ok := c.data[key]
-	if !ok {
-		return nil, false
+// newRequest creates a GET request, authenticated when a token is set.
+// The token is only ever placed in the Authorization header.
+func (f *GitHubFetcher) newRequest(url, accept string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
 	}
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	}
+	return req, nil
+}
 
-	// Cache expires after 5 minutes
-	if time.Since(entry.timestamp) > 5*time.Minute {
-		delete(c.data, key)
-		return nil, false
+// accessError describes a 401, 403, 404 or 429 response from GitHub.
+func (f *GitHubFetcher) accessError(resp *http.Response) error {
+	if rateLimited(resp) {
+		return fmt.Errorf("HTTP %d: GitHub API rate limit exceeded", resp.StatusCode)
 	}
+	return fmt.Errorf("HTTP %d: %w", resp.StatusCode, f.noAccess())
+}
 
-	return entry.data, true
+// rateLimited reports whether GitHub rejected the request for exceeding a
+// rate limit: a 429, or a 403 with no requests remaining.
+func rateLimited(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusForbidden && resp.Header.Get("X-RateLimit-Remaining") == "0")
 }
 
-// Set stores data in cache.
-func (c *Cache) Set(key string, data []byte) {
-	c.data[key] = cacheEntry{
-		data:      data,
-		timestamp: time.Now(),
-	}
+// noAccess returns the error for a repository that cannot be read.
+func (f *GitHubFetcher) noAccess() *AccessError {
+	return &AccessError{Repo: f.repo, Authenticated: f.token != ""}
 }
 
-// CacheDir returns the cache directory path.
-func CacheDir() (string, error) {
-	home, err := os.UserHomeDir()
+func (f *GitHubFetcher) fetch(key, url string) ([]byte, error) {
+	// Check cache first
+	if data, ok := f.cache.Get(key); ok {
+		return data, nil
+	}
+
+	cached, hasCached := f.disk.Load(key)
+
+	if f.offline {
+		return f.fetchOffline(key, cached, hasCached)
+	}
+	if hasCached && cached.NotFound {
+		// Negative entries carry no validators worth revalidating.
+		hasCached = false
+	}
+
+	accept := ""
+	if f.token != "" {
+		accept = "application/vnd.github.raw"
+	}
+	req, err := f.newRequest(url, accept)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if hasCached {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
 	}
-	return filepath.Join(home, ".cache", "crx"), nil
-}
 
-func matchesQuery(pkg *Package, query string) bool {
-	if contains(pkg.Name, query) || contains(pkg.DisplayName, query) {
-		return true
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if hasCached {
+			logger.Warn("registry unreachable, using cached copy", "file", key, "fetched_at", cached.FetchedAt, "error", err)
+			return cached.Data, nil
+		}
+		return nil, err
 	}
-	for _, tag := range pkg.Tags {
-		if contains(tag, query) {
-			return true
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && hasCached:
+		logger.Debug("registry file not modified", "file", key)
+		cached.FetchedAt = time.Now()
+		f.store(key, cached)
+		return cached.Data, nil
+	case resp.StatusCode == http.StatusNotFound:
+		// Remember missing files so offline lookups can tell "not in this
+		// registry" apart from "never fetched".
+		if err := f.disk.Store(key, &DiskEntry{NotFound: true, FetchedAt: time.Now()}); err != nil {
+			logger.Debug("failed to write disk cache", "file", key, "error", err)
 		}
+		return nil, ErrNotFound
+	case rateLimited(resp) && hasCached:
+		logger.Warn("registry rate limit exceeded, using cached copy", "file", key, "status", resp.StatusCode, "fetched_at", cached.FetchedAt)
+		return cached.Data, nil
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		return nil, f.accessError(resp)
+	case resp.StatusCode != http.StatusOK:
+		if hasCached && resp.StatusCode >= http.StatusInternalServerError {
+			logger.Warn("registry returned an error, using cached copy", "file", key, "status", resp.StatusCode, "fetched_at", cached.FetchedAt)
+			return cached.Data, nil
+		}
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
 	}
-	return false
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	f.store(key, &DiskEntry{
+		Data:         data,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now(),
+	})
+
+	return data, nil
 }
 
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(substr) == 0 ||
-		(len(s) > 0 && len(substr) > 0 && searchString(s, substr)))
+// fetchOffline serves a file from the disk cache without touching the network.
+func (f *GitHubFetcher) fetchOffline(key string, cached *DiskEntry, ok bool) ([]byte, error) {
+	if !ok {
+		return nil, fmt.Errorf("%w: %s has never been fetched; run once without --offline to cache it", ErrNotCached, key)
+	}
+	if cached.NotFound {
+		return nil, ErrNotFound
+	}
+	logger.Debug("serving registry file from offline cache", "file", key, "fetched_at", cached.FetchedAt)
+	f.cache.Set(key, cached.Data)
+	return cached.Data, nil
 }
 
-func searchString(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
+// store saves a fetched file in both the memory and the disk cache.
+func (f *GitHubFetcher) store(key string, entry *DiskEntry) {
+	f.cache.Set(key, entry.Data)
+	if err := f.disk.Store(key, entry); err != nil {
+		logger.Debug("failed to write disk cache", "file", key, "error", err)
 	}
-	return false
 }
@@ -0,0 +1,178 @@
+package registry
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestGitHubFetcherRevalidation(t *testing.T) {
+	const (
+		etag    = `"v1"`
+		cached  = "version: 1\npackages:\n  - vimium\n"
+		changed = "version: 1\npackages:\n  - dark-reader\n  - vimium\n"
+	)
+
+	tests := []struct {
+		name string
+		// revalidate responds to the request made once the file is cached.
+		revalidate func(w http.ResponseWriter)
+		// down stops the server before the file is fetched again.
+		down         bool
+		wantPackages []string
+	}{
+		{
+			name:         "not modified",
+			revalidate:   func(w http.ResponseWriter) { w.WriteHeader(http.StatusNotModified) },
+			wantPackages: []string{"vimium"},
+		},
+		{
+			name: "modified",
+			revalidate: func(w http.ResponseWriter) {
+				w.Header().Set("ETag", `"v2"`)
+				_, _ = w.Write([]byte(changed))
+			},
+			wantPackages: []string{"dark-reader", "vimium"},
+		},
+		{
+			name:         "server error",
+			revalidate:   func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) },
+			wantPackages: []string{"vimium"},
+		},
+		{
+			name: "rate limited",
+			revalidate: func(w http.ResponseWriter) {
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.WriteHeader(http.StatusForbidden)
+			},
+			wantPackages: []string{"vimium"},
+		},
+		{
+			name:         "unreachable",
+			down:         true,
+			wantPackages: []string{"vimium"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			var ifNoneMatch string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests == 1 {
+					w.Header().Set("ETag", etag)
+					_, _ = w.Write([]byte(cached))
+					return
+				}
+				ifNoneMatch = r.Header.Get("If-None-Match")
+				tt.revalidate(w)
+			}))
+			defer srv.Close()
+
+			first := newTestGitHubFetcher(t, srv)
+			if _, err := first.FetchRegistry(); err != nil {
+				t.Fatalf("FetchRegistry() error = %v", err)
+			}
+			if tt.down {
+				srv.Close()
+			}
+
+			// A new fetcher has an empty memory cache, like the next run.
+			second := NewGitHubFetcher("acme/registry", "main")
+			second.baseURL = first.baseURL
+			second.disk = first.disk
+			reg, err := second.FetchRegistry()
+			if err != nil {
+				t.Fatalf("FetchRegistry() error = %v", err)
+			}
+			if !reflect.DeepEqual(reg.Packages, tt.wantPackages) {
+				t.Errorf("packages = %v, want %v", reg.Packages, tt.wantPackages)
+			}
+			if !tt.down && ifNoneMatch != etag {
+				t.Errorf("If-None-Match = %q, want %q", ifNoneMatch, etag)
+			}
+		})
+	}
+
+	t.Run("server error without a cached copy", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer srv.Close()
+
+		if _, err := newTestGitHubFetcher(t, srv).FetchRegistry(); err == nil {
+			t.Error("FetchRegistry() error = nil, want an error")
+		}
+	})
+}
+
+func TestGitHubFetcherOffline(t *testing.T) {
+	var offline bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if offline {
+			t.Errorf("offline fetcher requested %s", r.URL.Path)
+		}
+		switch r.URL.Path {
+		case "/acme/registry/main/" + RegistryFile:
+			_, _ = w.Write([]byte("version: 1\npackages:\n  - vimium\n"))
+		case "/acme/registry/main/" + packagePath("vimium"):
+			_, _ = w.Write([]byte(testPackage))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	// Caches registry.yaml, vimium and the absence of missing.
+	online := newTestGitHubFetcher(t, srv)
+	if _, err := online.FetchPackage("vimium"); err != nil {
+		t.Fatalf("FetchPackage() error = %v", err)
+	}
+	if _, err := online.FetchPackage("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("FetchPackage() error = %v, want ErrNotFound", err)
+	}
+	offline = true
+
+	tests := []struct {
+		name    string
+		pkg     string
+		wantErr error
+	}{
+		{name: "cached", pkg: "vimium"},
+		{name: "cached as not found", pkg: "missing", wantErr: ErrNotFound},
+		{name: "never fetched", pkg: "dark-reader", wantErr: ErrNotCached},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewGitHubFetcher("acme/registry", "main", WithOffline(true))
+			f.baseURL = srv.URL
+			f.disk = online.disk
+
+			pkg, err := f.FetchPackage(tt.pkg)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("FetchPackage() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchPackage() error = %v", err)
+			}
+			if pkg.Name != tt.pkg {
+				t.Errorf("FetchPackage() = %s, want %s", pkg.Name, tt.pkg)
+			}
+		})
+	}
+
+	t.Run("revision", func(t *testing.T) {
+		f := NewGitHubFetcher("acme/registry", "main", WithOffline(true))
+		f.apiURL = srv.URL
+		if _, err := f.Revision(); !errors.Is(err, ErrNotCached) {
+			t.Errorf("Revision() error = %v, want ErrNotCached", err)
+		}
+	})
+}
@@ -0,0 +1,447 @@
+package registry
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is a lint finding in a registry file.
+type Problem struct {
+	File    string // relative to the registry root
+	Line    int
+	Column  int
+	Message string
+}
+
+// String formats the problem as "file:line:column: message".
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.File, p.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Message)
+}
+
+// linter collects problems while checking a local registry.
+type linter struct {
+	dir      string
+	problems []Problem
+}
+
+func (l *linter) report(file string, node *yaml.Node, format string, args ...any) {
+	p := Problem{
+		File:    file,
+		Message: fmt.Sprintf(format, args...),
+	}
+	if node != nil {
+		p.Line = node.Line
+		p.Column = node.Column
+	}
+	l.problems = append(l.problems, p)
+}
+
+// lintedPackage is a package file together with its parsed document.
+type lintedPackage struct {
+	file string
+	node *yaml.Node
+	pkg  Package
+}
+
+// Lint checks the local registry at dir against the registry schema and
+// returns every problem found, sorted by file and position.
+func Lint(dir string) ([]Problem, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to open registry: %w", err)
+	}
+
+	l := &linter{dir: dir}
+	reg, regRoot := l.lintRegistry()
+	packages, err := l.lintPackages()
+	if err != nil {
+		return nil, err
+	}
+	l.crossCheck(reg, regRoot, packages)
+
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		a, b := l.problems[i], l.problems[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return l.problems, nil
+}
+
+// lintRegistry checks registry.yaml and returns it with its document.
+func (l *linter) lintRegistry() (*Registry, *yaml.Node) {
+	root, ok := l.parse(RegistryFile)
+	if !ok {
+		return nil, nil
+	}
+
+	l.checkFields(RegistryFile, root, reflect.TypeOf(Registry{}))
+
+	var reg Registry
+	if err := root.Decode(&reg); err != nil {
+		l.report(RegistryFile, root, "invalid registry: %v", err)
+		return nil, nil
+	}
+
+	if _, v := mappingEntry(root, "version"); v == nil {
+		l.report(RegistryFile, root, "version is required")
+	} else if reg.Version != RegistryV1 && reg.Version != RegistryV2 {
+		l.report(RegistryFile, v, "unsupported version %d", reg.Version)
+	}
+
+	_, list := mappingEntry(root, "packages")
+	if list == nil {
+		l.report(RegistryFile, root, "packages is required")
+		return &reg, root
+	}
+
+	seen := make(map[string]bool)
+	for _, item := range list.Content {
+		if seen[item.Value] {
+			l.report(RegistryFile, item, "package %q is listed more than once", item.Value)
+		}
+		seen[item.Value] = true
+	}
+
+	return &reg, root
+}
+
+// lintPackages checks every file in the pkgs directory.
+func (l *linter) lintPackages() ([]*lintedPackage, error) {
+	paths, err := filepath.Glob(filepath.Join(l.dir, PackagesDir, "*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list package files: %w", err)
+	}
+	sort.Strings(paths)
+
+	var packages []*lintedPackage
+	for _, path := range paths {
+		file := PackagesDir + "/" + filepath.Base(path)
+		if filepath.Ext(path) != ".yaml" {
+			l.report(file, nil, "package files must have the .yaml extension")
+			continue
+		}
+
+		root, ok := l.parse(file)
+		if !ok {
+			continue
+		}
+		l.checkFields(file, root, reflect.TypeOf(Package{}))
+
+		lp := &lintedPackage{file: file, node: root}
+		if err := root.Decode(&lp.pkg); err != nil {
+			l.report(file, root, "invalid package: %v", err)
+			continue
+		}
+		l.lintPackage(lp)
+		packages = append(packages, lp)
+	}
+
+	return packages, nil
+}
+
+// lintPackage checks the fields of a single package.
+func (l *linter) lintPackage(lp *lintedPackage) {
+	for _, field := range []string{"name", "id", "display_name"} {
+		if k, v := mappingEntry(lp.node, field); v == nil || v.Value == "" {
+			node := lp.node
+			if k != nil {
+				node = k
+			}
+			l.report(lp.file, node, "%s is required", field)
+		}
+	}
+
+	want := strings.TrimSuffix(filepath.Base(lp.file), ".yaml")
+	if _, v := mappingEntry(lp.node, "name"); v != nil && v.Value != "" {
+		if err := ValidateName(v.Value); err != nil {
+			l.report(lp.file, v, "%v", err)
+		}
+		if v.Value != want {
+			l.report(lp.file, v, "name %q does not match file name %q", v.Value, want)
+		}
+	}
+
+	if _, v := mappingEntry(lp.node, "id"); v != nil && v.Value != "" {
+		if err := ValidateID(v.Value); err != nil {
+			l.report(lp.file, v, "%v", err)
+		}
+	}
+
+	_, aliases := mappingEntry(lp.node, "aliases")
+	if aliases != nil {
+		for _, item := range aliases.Content {
+			if err := ValidateName(item.Value); err != nil {
+				l.report(lp.file, item, "alias: %v", err)
+			}
+		}
+	}
+
+	if !lp.pkg.Deprecated {
+		for _, field := range []string{"deprecation_message", "replaced_by"} {
+			if k, _ := mappingEntry(lp.node, field); k != nil {
+				l.report(lp.file, k, "%s is set but the package is not deprecated", field)
+			}
+		}
+	}
+
+	for _, field := range []string{"homepage", "repository"} {
+		_, v := mappingEntry(lp.node, field)
+		if v == nil || v.Value == "" {
+			continue
+		}
+		if !isHTTPURL(v.Value) {
+			l.report(lp.file, v, "%s must be an http(s) URL", field)
+		}
+	}
+	l.checkUpdateURL(lp.file, "update_url", lp.node)
+
+	for _, store := range []string{StoreChromeWebStore, StoreEdgeAddons} {
+		_, listing := mappingEntry(lp.node, store)
+		if listing == nil {
+			continue
+		}
+		if listing.Kind != yaml.MappingNode {
+			l.report(lp.file, listing, "%s must be a mapping", store)
+			continue
+		}
+		l.checkFields(lp.file, listing, reflect.TypeOf(Listing{}))
+		if k, v := mappingEntry(listing, "id"); v == nil || v.Value == "" {
+			node := listing
+			if k != nil {
+				node = k
+			}
+			l.report(lp.file, node, "%s.id is required", store)
+		} else if err := ValidateID(v.Value); err != nil {
+			l.report(lp.file, v, "%s.id: %v", store, err)
+		}
+		l.checkUpdateURL(lp.file, store+".update_url", listing)
+	}
+
+	if _, listing := mappingEntry(lp.node, StoreFirefox); listing != nil {
+		if listing.Kind != yaml.MappingNode {
+			l.report(lp.file, listing, "%s must be a mapping", StoreFirefox)
+			return
+		}
+		l.checkFields(lp.file, listing, reflect.TypeOf(FirefoxListing{}))
+		for _, field := range []string{"addon_id", "slug"} {
+			if k, v := mappingEntry(listing, field); v == nil || v.Value == "" {
+				node := listing
+				if k != nil {
+					node = k
+				}
+				l.report(lp.file, node, "%s.%s is required", StoreFirefox, field)
+			}
+		}
+		if _, v := mappingEntry(listing, "addon_id"); v != nil && v.Value != "" {
+			if err := ValidateFirefoxID(v.Value); err != nil {
+				l.report(lp.file, v, "%s.addon_id: %v", StoreFirefox, err)
+			}
+		}
+		if _, v := mappingEntry(listing, "slug"); v != nil && v.Value != "" && !slugPattern.MatchString(v.Value) {
+			l.report(lp.file, v, "%s.slug: invalid slug %q", StoreFirefox, v.Value)
+		}
+	}
+}
+
+// checkUpdateURL checks the update_url entry of node, reported as field.
+func (l *linter) checkUpdateURL(file, field string, node *yaml.Node) {
+	_, v := mappingEntry(node, "update_url")
+	if v == nil || v.Value == "" {
+		return
+	}
+	if u, err := parseUpdateURL(v.Value); err != nil {
+		l.report(file, v, "%s must be an http(s) URL", field)
+	} else if u.Scheme != "https" {
+		l.report(file, v, "%s should use https; clients must allow %s in settings.insecure_update_hosts", field, u.Hostname())
+	}
+}
+
+// crossCheck checks consistency between registry.yaml and the package files.
+func (l *linter) crossCheck(reg *Registry, root *yaml.Node, packages []*lintedPackage) {
+	files := make(map[string]*lintedPackage, len(packages))
+	for _, lp := range packages {
+		files[strings.TrimSuffix(filepath.Base(lp.file), ".yaml")] = lp
+	}
+
+	ids := make(map[string]*lintedPackage)
+	for _, lp := range packages {
+		if lp.pkg.ID == "" {
+			continue
+		}
+		if other, ok := ids[lp.pkg.ID]; ok {
+			_, v := mappingEntry(lp.node, "id")
+			l.report(lp.file, v, "duplicate ID %s, also used by %s", lp.pkg.ID, other.file)
+			continue
+		}
+		ids[lp.pkg.ID] = lp
+	}
+
+	// Aliases must not shadow package names or other aliases.
+	aliases := make(map[string]*lintedPackage)
+	for _, lp := range packages {
+		_, node := mappingEntry(lp.node, "aliases")
+		if node == nil {
+			continue
+		}
+		for _, item := range node.Content {
+			if _, ok := files[item.Value]; ok {
+				l.report(lp.file, item, "alias %q collides with package %s", item.Value, packagePath(item.Value))
+				continue
+			}
+			if other, ok := aliases[item.Value]; ok && other != lp {
+				l.report(lp.file, item, "alias %q is also declared by %s", item.Value, other.file)
+				continue
+			}
+			aliases[item.Value] = lp
+
+			// Clients resolve aliases from registry.yaml, or from the bundled
+			// index of version 2 registries without aliases there.
+			if reg != nil && (reg.Aliases != nil || reg.Version == RegistryV1) && reg.Aliases[item.Value] != lp.pkg.Name {
+				l.report(lp.file, item, "alias %q is not mapped to %s in %s aliases; run 'crx registry build'", item.Value, lp.pkg.Name, RegistryFile)
+			}
+		}
+	}
+
+	for _, lp := range packages {
+		_, v := mappingEntry(lp.node, "replaced_by")
+		if v == nil || v.Value == "" {
+			continue
+		}
+		if v.Value == lp.pkg.Name {
+			l.report(lp.file, v, "replaced_by must name another package")
+		} else if _, ok := files[v.Value]; !ok {
+			l.report(lp.file, v, "replaced_by refers to unknown package %q", v.Value)
+		}
+	}
+
+	if reg == nil {
+		return
+	}
+
+	if _, node := mappingEntry(root, "aliases"); node != nil {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			alias, target := node.Content[i], node.Content[i+1]
+			if lp, ok := aliases[alias.Value]; !ok || lp.pkg.Name != target.Value {
+				l.report(RegistryFile, alias, "alias %q is not declared by package %q", alias.Value, target.Value)
+			}
+		}
+	}
+
+	_, list := mappingEntry(root, "packages")
+	if list == nil {
+		return
+	}
+
+	listed := make(map[string]bool)
+	for _, item := range list.Content {
+		listed[item.Value] = true
+		if _, ok := files[item.Value]; !ok {
+			l.report(RegistryFile, item, "package %q has no %s file", item.Value, packagePath(item.Value))
+		}
+	}
+	for name, lp := range files {
+		if !listed[name] {
+			l.report(lp.file, nil, "package %q is not listed in %s", name, RegistryFile)
+		}
+	}
+
+	if reg.Version == RegistryV2 {
+		l.lintIndex(packages)
+	}
+}
+
+// lintIndex checks that index.yaml matches the package files.
+func (l *linter) lintIndex(packages []*lintedPackage) {
+	data, err := os.ReadFile(filepath.Join(l.dir, IndexFile))
+	if os.IsNotExist(err) {
+		l.report(RegistryFile, nil, "version 2 registry has no %s; run 'crx registry build'", IndexFile)
+		return
+	}
+	if err != nil {
+		l.report(IndexFile, nil, "failed to read: %v", err)
+		return
+	}
+
+	idx, err := parseIndex(data)
+	if err != nil {
+		l.report(IndexFile, nil, "%v", err)
+		return
+	}
+
+	stale := len(idx.Packages) != len(packages)
+	for _, lp := range packages {
+		raw, err := os.ReadFile(filepath.Join(l.dir, filepath.FromSlash(lp.file)))
+		if err != nil || idx.Digests[lp.pkg.Name] != Digest(raw) {
+			stale = true
+			break
+		}
+	}
+	if stale {
+		l.report(IndexFile, nil, "%s is out of date; run 'crx registry build'", IndexFile)
+	}
+}
+
+// parse reads and parses a YAML file, reporting any error.
+func (l *linter) parse(file string) (*yaml.Node, bool) {
+	data, err := os.ReadFile(filepath.Join(l.dir, filepath.FromSlash(file)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			l.report(file, nil, "file is missing")
+		} else {
+			l.report(file, nil, "failed to read: %v", err)
+		}
+		return nil, false
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		l.report(file, nil, "%v", err)
+		return nil, false
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		l.report(file, nil, "must be a YAML mapping")
+		return nil, false
+	}
+	return doc.Content[0], true
+}
+
+// checkFields reports keys of node that do not belong to the struct type t.
+func (l *linter) checkFields(file string, node *yaml.Node, t reflect.Type) {
+	known := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if tag != "" && tag != "-" {
+			known[tag] = true
+		}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if !known[key.Value] {
+			l.report(file, key, "unknown field %q", key.Value)
+		}
+	}
+}
+
+// mappingEntry returns the key and value nodes for key in a mapping node.
+func mappingEntry(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
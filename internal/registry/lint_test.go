@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	const registryFile = "version: 1\npackages:\n  - vimium\n"
+
+	tests := []struct {
+		name     string
+		registry string
+		// packages are the package files, by file name.
+		packages map[string]string
+		// want are the expected problems, each given by a prefix of its
+		// string form, in order.
+		want []string
+	}{
+		{
+			name:     "clean",
+			registry: registryFile,
+			packages: map[string]string{"vimium.yaml": testPackage},
+		},
+		{
+			name:     "invalid ID",
+			registry: registryFile,
+			packages: map[string]string{"vimium.yaml": "name: vimium\nid: not-an-id\ndisplay_name: Vimium\n"},
+			want:     []string{"pkgs/vimium.yaml:2:5: invalid extension ID"},
+		},
+		{
+			name:     "unknown field and missing field",
+			registry: registryFile,
+			packages: map[string]string{"vimium.yaml": "name: vimium\nid: dbepggeogbaibhgnhhndojpepiihcmeb\nhomepage: https://vimium.github.io\n  \ndisplayname: Vimium\n"},
+			want: []string{
+				"pkgs/vimium.yaml:1:1: display_name is required",
+				`pkgs/vimium.yaml:5:1: unknown field "displayname"`,
+			},
+		},
+		{
+			name:     "nested listing",
+			registry: registryFile,
+			packages: map[string]string{"vimium.yaml": testPackage + "edge_addons:\n  id: dbepggeogbaibhgnhhndojpepiihcmeb\n  update_url: http://example.com/update.xml\n"},
+			want:     []string{"pkgs/vimium.yaml:6:15: edge_addons.update_url should use https"},
+		},
+		{
+			name:     "registry.yaml out of sync, sorted by file and position",
+			registry: "version: 1\npackages:\n  - vimium\n  - removed\n  - vimium\n",
+			packages: map[string]string{
+				"vimium.yaml":      testPackage,
+				"dark-reader.yaml": "name: dark-reader\nid: eimadpbcbfnmbkopoojfekhnkhdbieeh\ndisplay_name: Dark Reader\n",
+			},
+			want: []string{
+				`pkgs/dark-reader.yaml: package "dark-reader" is not listed in registry.yaml`,
+				`registry.yaml:4:5: package "removed" has no pkgs/removed.yaml file`,
+				`registry.yaml:5:5: package "vimium" is listed more than once`,
+			},
+		},
+		{
+			name:     "unsupported version",
+			registry: "version: 3\npackages:\n  - vimium\n",
+			packages: map[string]string{"vimium.yaml": testPackage},
+			want:     []string{"registry.yaml:1:10: unsupported version 3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFile(t, filepath.Join(dir, RegistryFile), tt.registry)
+			for file, content := range tt.packages {
+				writeTestFile(t, filepath.Join(dir, PackagesDir, file), content)
+			}
+
+			problems, err := Lint(dir)
+			if err != nil {
+				t.Fatalf("Lint() error = %v", err)
+			}
+			got := make([]string, len(problems))
+			for i, p := range problems {
+				got[i] = p.String()
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Lint() = %q, want %q", got, tt.want)
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(got[i], want) {
+					t.Errorf("problem %d = %q, want it to start with %q", i, got[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LocalFetcher fetches registry data from a directory on disk.
+type LocalFetcher struct {
+	dir    string
+	index  bundledIndex
+	verify *verifier
+}
+
+// NewLocalFetcher creates a new LocalFetcher for the registry rooted at dir.
+func NewLocalFetcher(dir string, opts ...Option) *LocalFetcher {
+	o := newOptions(opts)
+	return &LocalFetcher{
+		dir:    dir,
+		verify: newVerifier(o.publicKeys),
+	}
+}
+
+// FetchRegistry fetches the registry index.
+func (f *LocalFetcher) FetchRegistry() (*Registry, error) {
+	data, err := f.read(RegistryFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", RegistryFile, err)
+	}
+	return parseRegistry(data)
+}
+
+// FetchPackage fetches a package definition.
+// The bundled index is used when the registry publishes one.
+func (f *LocalFetcher) FetchPackage(name string) (*Package, error) {
+	// The name becomes part of a file path.
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	if pkg, ok, err := f.index.lookup(f, name); ok {
+		return pkg, err
+	}
+
+	data, err := f.read(packagePath(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package %s: %w", name, err)
+	}
+	return parsePackage(name, data)
+}
+
+// FetchAllPackages fetches all packages listed in the registry.
+// The bundled index is used when the registry publishes one.
+func (f *LocalFetcher) FetchAllPackages() ([]*Package, error) {
+	if packages, ok, err := f.index.all(f); ok {
+		return packages, err
+	}
+	return fetchAll(f)
+}
+
+// Search searches packages by name or tag.
+func (f *LocalFetcher) Search(query string) ([]*Package, error) {
+	return search(f, query)
+}
+
+func (f *LocalFetcher) read(path string) ([]byte, error) {
+	data, err := f.readRaw(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.verify.check(f.readRaw, path, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (f *LocalFetcher) readRaw(path string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(f.dir, filepath.FromSlash(path)))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
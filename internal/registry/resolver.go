@@ -0,0 +1,146 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sivchari/crx/internal/logger"
+)
+
+// Source is a named registry fetcher.
+type Source struct {
+	Name    string
+	Fetcher Fetcher
+}
+
+// Resolver resolves packages across multiple registries in priority order.
+type Resolver struct {
+	sources []Source
+}
+
+// NewResolver creates a new Resolver. Sources are searched in the given order.
+func NewResolver(sources ...Source) *Resolver {
+	return &Resolver{
+		sources: sources,
+	}
+}
+
+// Sources returns the registries in priority order.
+func (r *Resolver) Sources() []Source {
+	return r.sources
+}
+
+// FetchPackage fetches a package from the first registry that has it.
+// Errors other than a missing package stop the lookup, so a failing
+// high-priority registry never silently falls through to a lower one. A
+// GitHub registry that cannot be accessed fails with an *AccessError.
+// If no registry has a package with that name, aliases are searched.
+func (r *Resolver) FetchPackage(name string) (*Package, error) {
+	for _, src := range r.sources {
+		pkg, err := src.Fetcher.FetchPackage(name)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("registry %s: %w", src.Name, err)
+		}
+		pkg.Registry = src.Name
+		return pkg, nil
+	}
+
+	return r.fetchAlias(name)
+}
+
+// fetchAlias finds the package that declares name as an alias. A registry
+// whose aliases cannot be read is skipped, so that the name is reported as
+// not found instead of failing the lookup, unless the registry cannot be
+// accessed at all.
+func (r *Resolver) fetchAlias(name string) (*Package, error) {
+	for _, src := range r.sources {
+		pkg, err := findAlias(src.Fetcher, name)
+		var accessErr *AccessError
+		if errors.As(err, &accessErr) {
+			return nil, fmt.Errorf("registry %s: %w", src.Name, err)
+		}
+		if err != nil {
+			logger.Debug("failed to search aliases", "registry", src.Name, "alias", name, "error", err)
+			continue
+		}
+		if pkg == nil {
+			continue
+		}
+		pkg.Registry = src.Name
+		logger.Debug("package resolved by alias", "alias", name, "name", pkg.Name, "registry", pkg.Registry)
+		return pkg, nil
+	}
+	return nil, fmt.Errorf("package %s: %w in any registry", name, ErrNotFound)
+}
+
+// findAlias returns the package of f that declares name as an alias, or
+// nil if there is none. It reads the aliases of registry.yaml and, for
+// version 2 registries built without them, the bundled index; package
+// files are never fetched one by one.
+func findAlias(f Fetcher, name string) (*Package, error) {
+	reg, err := f.FetchRegistry()
+	if err != nil {
+		return nil, err
+	}
+	if target, ok := reg.Aliases[name]; ok {
+		return f.FetchPackage(target)
+	}
+	if reg.Aliases != nil || reg.Version < RegistryV2 {
+		return nil, nil
+	}
+
+	packages, err := f.FetchAllPackages()
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range packages {
+		if pkg.HasAlias(name) {
+			return pkg, nil
+		}
+	}
+	return nil, nil
+}
+
+// FetchAllPackages fetches all packages from every registry.
+// When a name exists in several registries, the highest-priority one wins.
+func (r *Resolver) FetchAllPackages() ([]*Package, error) {
+	seen := make(map[string]bool)
+	var packages []*Package
+	for _, src := range r.sources {
+		pkgs, err := src.Fetcher.FetchAllPackages()
+		if err != nil {
+			return nil, fmt.Errorf("registry %s: %w", src.Name, err)
+		}
+		for _, pkg := range pkgs {
+			if seen[pkg.Name] {
+				continue
+			}
+			seen[pkg.Name] = true
+			pkg.Registry = src.Name
+			packages = append(packages, pkg)
+		}
+	}
+	return packages, nil
+}
+
+// FetchRegistry returns a merged index of every registry.
+func (r *Resolver) FetchRegistry() (*Registry, error) {
+	packages, err := r.FetchAllPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	reg := &Registry{Version: 1}
+	for _, pkg := range packages {
+		reg.Packages = append(reg.Packages, pkg.Name)
+	}
+	return reg, nil
+}
+
+// Search searches packages across every registry by name or tag.
+func (r *Resolver) Search(query string) ([]*Package, error) {
+	return search(r, query)
+}
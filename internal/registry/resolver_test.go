@@ -0,0 +1,87 @@
+package registry
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestGitHubFetcher returns a fetcher of acme/registry that fetches from
+// srv and caches in a temporary directory.
+func newTestGitHubFetcher(t *testing.T, srv *httptest.Server, opts ...Option) *GitHubFetcher {
+	t.Helper()
+	f := NewGitHubFetcher("acme/registry", "main", opts...)
+	f.baseURL = srv.URL
+	f.apiURL = srv.URL
+	f.disk = NewDiskCache(t.TempDir())
+	return f
+}
+
+func TestResolverAccessError(t *testing.T) {
+	tests := []struct {
+		name string
+		// files are the registry files the server has, by path.
+		files map[string]string
+		// status is the status of every other request.
+		status     int
+		token      string
+		wantAccess bool
+		// wantErr is a part of the expected error.
+		wantErr string
+	}{
+		{
+			name:       "repository missing or private",
+			status:     http.StatusNotFound,
+			wantAccess: true,
+			wantErr:    "registry private: failed to fetch package vimium: repository acme/registry does not exist or is private; set GITHUB_TOKEN",
+		},
+		{
+			name:       "token lacks access",
+			status:     http.StatusNotFound,
+			token:      "token",
+			wantAccess: true,
+			wantErr:    "repository acme/registry is private or token lacks access",
+		},
+		{
+			name:       "token rejected",
+			status:     http.StatusUnauthorized,
+			token:      "token",
+			wantAccess: true,
+			wantErr:    "HTTP 401: repository acme/registry is private or token lacks access",
+		},
+		{
+			name:    "package missing",
+			files:   map[string]string{"/acme/registry/main/" + RegistryFile: "version: 1\npackages: []\n"},
+			status:  http.StatusNotFound,
+			wantErr: "package vimium: not found in any registry",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if data, ok := tt.files[r.URL.Path]; ok {
+					_, _ = w.Write([]byte(data))
+					return
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			r := NewResolver(Source{Name: "private", Fetcher: newTestGitHubFetcher(t, srv, WithToken(tt.token))})
+			_, err := r.FetchPackage("vimium")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("FetchPackage() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			var accessErr *AccessError
+			if got := errors.As(err, &accessErr); got != tt.wantAccess {
+				t.Errorf("FetchPackage() error is an *AccessError = %v, want %v", got, tt.wantAccess)
+			}
+			if !tt.wantAccess && !errors.Is(err, ErrNotFound) {
+				t.Errorf("FetchPackage() error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package registry
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Init creates an empty version 2 registry in dir: registry.yaml, an empty
+// pkgs directory and the bundled index.
+func Init(dir string) error {
+	regPath := filepath.Join(dir, RegistryFile)
+	if _, err := os.Stat(regPath); err == nil {
+		return fmt.Errorf("%s already exists", regPath)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, PackagesDir), 0755); err != nil {
+		return fmt.Errorf("failed to create registry directory: %w", err)
+	}
+
+	reg := &Registry{
+		Version:  RegistryV2,
+		Packages: []string{},
+	}
+	if err := writeYAML(regPath, reg); err != nil {
+		return err
+	}
+
+	if _, err := BuildIndex(dir); err != nil {
+		return err
+	}
+	return nil
+}
+
+// AddPackage writes pkg to the pkgs directory of the local registry at dir
+// and inserts its name into registry.yaml in sorted order. The bundled
+// index is regenerated for version 2 registries.
+func AddPackage(dir string, pkg *Package) error {
+	if err := pkg.Validate(); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, RegistryFile))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", RegistryFile, err)
+	}
+	reg, err := parseRegistry(data)
+	if err != nil {
+		return err
+	}
+
+	pkgPath := filepath.Join(dir, filepath.FromSlash(packagePath(pkg.Name)))
+	if _, err := os.Stat(pkgPath); err == nil {
+		return fmt.Errorf("package %s already exists", pkg.Name)
+	}
+
+	existing, err := LoadPackageFiles(dir)
+	if err != nil {
+		return err
+	}
+	for _, other := range existing {
+		if other.ID == pkg.ID {
+			return fmt.Errorf("extension ID %s is already used by package %s", pkg.ID, other.Name)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(pkgPath), 0755); err != nil {
+		return fmt.Errorf("failed to create %s directory: %w", PackagesDir, err)
+	}
+	if err := writeYAML(pkgPath, pkg); err != nil {
+		return err
+	}
+
+	if !slices.Contains(reg.Packages, pkg.Name) {
+		reg.Packages = append(reg.Packages, pkg.Name)
+	}
+	slices.Sort(reg.Packages)
+	if err := writeYAML(filepath.Join(dir, RegistryFile), reg); err != nil {
+		return err
+	}
+
+	if reg.Version >= RegistryV2 {
+		if _, err := BuildIndex(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package registry
+
+import (
+	"sort"
+	"strings"
+)
+
+// Field weights used by Rank. Exact name matches always rank first.
+const (
+	scoreExactName    = 1000
+	scoreExactAlias   = 900
+	scoreExactDisplay = 850
+	scorePrefixName   = 700
+	scorePrefixDisp   = 600
+	scoreSubName      = 500
+	scoreSubDisplay   = 450
+	scoreExactTag     = 400
+	scoreSubTag       = 300
+	scoreSubDesc      = 200
+	scoreFuzzyName    = 150
+	scoreFuzzyDisplay = 120
+	scoreFuzzyTag     = 80
+	scoreFuzzyDesc    = 50
+)
+
+// Rank returns the packages that match query, best matches first.
+// Matching is case-insensitive and covers the name, aliases, display name,
+// description and tags. Every whitespace-separated term of the query must
+// match, either as a substring or fuzzily as an in-order subsequence.
+// An empty query matches every package, sorted by name.
+func Rank(packages []*Package, query string) []*Package {
+	terms := strings.Fields(strings.ToLower(query))
+
+	type scored struct {
+		pkg   *Package
+		score int
+	}
+	var results []scored
+	for _, pkg := range packages {
+		total := 0
+		matched := true
+		for _, term := range terms {
+			s := scoreTerm(pkg, term)
+			if s == 0 {
+				matched = false
+				break
+			}
+			total += s
+		}
+		if matched {
+			results = append(results, scored{pkg: pkg, score: total})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return results[i].pkg.Name < results[j].pkg.Name
+	})
+
+	ranked := make([]*Package, 0, len(results))
+	for _, r := range results {
+		ranked = append(ranked, r.pkg)
+	}
+	return ranked
+}
+
+// FilterByTags returns the packages that have every one of tags.
+// Tags are compared case-insensitively.
+func FilterByTags(packages []*Package, tags []string) []*Package {
+	if len(tags) == 0 {
+		return packages
+	}
+
+	var filtered []*Package
+	for _, pkg := range packages {
+		ok := true
+		for _, tag := range tags {
+			if !pkg.HasTag(tag) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			filtered = append(filtered, pkg)
+		}
+	}
+	return filtered
+}
+
+// HasTag reports whether the package has tag, ignoring case.
+func (p *Package) HasTag(tag string) bool {
+	for _, t := range p.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// scoreTerm returns the best score of a lowercase term against pkg,
+// or 0 if it does not match.
+func scoreTerm(pkg *Package, term string) int {
+	name := strings.ToLower(pkg.Name)
+	display := strings.ToLower(pkg.DisplayName)
+	desc := strings.ToLower(pkg.Description)
+
+	best := 0
+	try := func(score int) {
+		best = max(best, score)
+	}
+
+	switch {
+	case name == term:
+		try(scoreExactName)
+	case strings.HasPrefix(name, term):
+		try(scorePrefixName)
+	case strings.Contains(name, term):
+		try(scoreSubName)
+	default:
+		try(fuzzyScore(name, term, scoreFuzzyName))
+	}
+
+	for _, alias := range pkg.Aliases {
+		if strings.ToLower(alias) == term {
+			try(scoreExactAlias)
+		}
+	}
+
+	switch {
+	case display == term:
+		try(scoreExactDisplay)
+	case strings.HasPrefix(display, term):
+		try(scorePrefixDisp)
+	case strings.Contains(display, term):
+		try(scoreSubDisplay)
+	default:
+		try(fuzzyScore(display, term, scoreFuzzyDisplay))
+	}
+
+	for _, tag := range pkg.Tags {
+		tag = strings.ToLower(tag)
+		switch {
+		case tag == term:
+			try(scoreExactTag)
+		case strings.Contains(tag, term):
+			try(scoreSubTag)
+		default:
+			try(fuzzyScore(tag, term, scoreFuzzyTag))
+		}
+	}
+
+	if strings.Contains(desc, term) {
+		try(scoreSubDesc)
+	} else {
+		try(fuzzyScore(desc, term, scoreFuzzyDesc))
+	}
+
+	return best
+}
+
+// fuzzyScore reports how well term matches s as an in-order subsequence.
+// It returns 0 if term is not a subsequence of s or if the matched
+// characters are spread too far apart; otherwise base reduced by the number
+// of skipped characters between matches, but never below half of base.
+func fuzzyScore(s, term string, base int) int {
+	if term == "" || s == "" {
+		return 0
+	}
+
+	sr := []rune(s)
+	gaps := 0
+	pos := 0
+	started := false
+	for _, c := range term {
+		found := false
+		for pos < len(sr) {
+			if sr[pos] == c {
+				found = true
+				pos++
+				break
+			}
+			if started {
+				gaps++
+			}
+			pos++
+		}
+		if !found {
+			return 0
+		}
+		started = true
+	}
+
+	if gaps > 2*len(term) {
+		return 0
+	}
+	return max(base-gaps, base/2)
+}
@@ -0,0 +1,75 @@
+package registry
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRank(t *testing.T) {
+	packages := []*Package{
+		{Name: "vimium", DisplayName: "Vimium", Description: "The hacker's browser", Tags: []string{"keyboard", "navigation"}},
+		{Name: "vimium-c", DisplayName: "Vimium C", Tags: []string{"keyboard"}},
+		{Name: "surfingkeys", DisplayName: "Surfingkeys", Description: "Vim-like navigation", Tags: []string{"keyboard"}},
+		{Name: "ublock-origin-lite", DisplayName: "uBlock Origin Lite", Aliases: []string{"ubol"}, Tags: []string{"adblock"}},
+		{Name: "dark-reader", DisplayName: "Dark Reader", Description: "Dark mode for every website", Tags: []string{"theme"}},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "exact name before prefix",
+			query: "vimium",
+			want:  []string{"vimium", "vimium-c"},
+		},
+		{
+			name:  "alias",
+			query: "ubol",
+			want:  []string{"ublock-origin-lite"},
+		},
+		{
+			name:  "display name, ignoring case",
+			query: "DARK READER",
+			want:  []string{"dark-reader"},
+		},
+		{
+			name:  "equal scores by name",
+			query: "keyboard",
+			want:  []string{"surfingkeys", "vimium", "vimium-c"},
+		},
+		{
+			name:  "name before description",
+			query: "vim keyboard",
+			want:  []string{"vimium", "vimium-c", "surfingkeys"},
+		},
+		{
+			name:  "fuzzy",
+			query: "vmm",
+			want:  []string{"vimium", "vimium-c"},
+		},
+		{
+			name:  "every term must match",
+			query: "vimium adblock",
+			want:  []string{},
+		},
+		{
+			name:  "empty query matches all by name",
+			query: "",
+			want:  []string{"dark-reader", "surfingkeys", "ublock-origin-lite", "vimium", "vimium-c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, pkg := range Rank(packages, tt.query) {
+				got = append(got, pkg.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Rank(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
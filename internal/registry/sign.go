@@ -0,0 +1,209 @@
+package registry
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sivchari/crx/internal/fsutil"
+)
+
+// Signature file layout.
+const (
+	ManifestFile  = "manifest.yaml"
+	SignatureFile = "manifest.yaml.sig"
+)
+
+// ErrVerification is returned when registry content fails signature or
+// digest verification.
+var ErrVerification = errors.New("registry verification failed")
+
+// Manifest lists the digest of every file published by a registry.
+// Its signature covers registry.yaml and each package file transitively.
+type Manifest struct {
+	Version int               `yaml:"version"`
+	Files   map[string]string `yaml:"files"`
+}
+
+// Digest returns the content digest of data in "sha256:<hex>" form.
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// ParsePublicKey parses a base64-encoded ed25519 public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key: want %d bytes, got %d", ed25519.PublicKeySize, len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// EncodePublicKey returns the base64 encoding of an ed25519 public key,
+// as used in the public_keys setting of a registry.
+func EncodePublicKey(key ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// LoadPrivateKey loads an ed25519 private key from a PEM-encoded PKCS #8
+// file, such as one created by "openssl genpkey -algorithm ed25519".
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("key file %s is not a PEM-encoded private key", path)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("key file %s is not an ed25519 key", path)
+	}
+	return priv, nil
+}
+
+// Sign writes manifest.yaml with the digest of every registry file in the
+// local registry at dir, and manifest.yaml.sig with its signature.
+func Sign(dir string, key ed25519.PrivateKey) (*Manifest, error) {
+	manifest := &Manifest{
+		Version: 1,
+		Files:   make(map[string]string),
+	}
+
+	paths := []string{RegistryFile, IndexFile}
+	pkgFiles, err := filepath.Glob(filepath.Join(dir, PackagesDir, "*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list package files: %w", err)
+	}
+	for _, p := range pkgFiles {
+		paths = append(paths, PackagesDir+"/"+filepath.Base(p))
+	}
+
+	for _, p := range paths {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(p)))
+		if os.IsNotExist(err) && p == IndexFile {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		manifest.Files[p] = Digest(data)
+	}
+
+	data, err := marshalYAML(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", ManifestFile, err)
+	}
+
+	sig := ed25519.Sign(key, data)
+	if err := fsutil.WriteFileAtomic(filepath.Join(dir, ManifestFile), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", ManifestFile, err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(sig) + "\n"
+	if err := fsutil.WriteFileAtomic(filepath.Join(dir, SignatureFile), []byte(encoded), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", SignatureFile, err)
+	}
+
+	return manifest, nil
+}
+
+// verifier checks registry files against a signed manifest.
+// A nil verifier accepts everything.
+type verifier struct {
+	keys []string
+
+	once    sync.Once
+	digests map[string]string
+	err     error
+}
+
+func newVerifier(keys []string) *verifier {
+	if len(keys) == 0 {
+		return nil
+	}
+	return &verifier{
+		keys: keys,
+	}
+}
+
+// check verifies that data is the signed content of path. The manifest is
+// read with readRaw on first use.
+func (v *verifier) check(readRaw func(string) ([]byte, error), path string, data []byte) error {
+	if v == nil {
+		return nil
+	}
+
+	v.once.Do(func() {
+		v.digests, v.err = v.loadManifest(readRaw)
+	})
+	if v.err != nil {
+		return v.err
+	}
+
+	want, ok := v.digests[path]
+	if !ok {
+		return fmt.Errorf("%w: %s is not listed in the signed manifest", ErrVerification, path)
+	}
+	if got := Digest(data); got != want {
+		return fmt.Errorf("%w: %s digest mismatch (manifest %s, got %s)", ErrVerification, path, want, got)
+	}
+	return nil
+}
+
+func (v *verifier) loadManifest(readRaw func(string) ([]byte, error)) (map[string]string, error) {
+	data, err := readRaw(ManifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to fetch %s: %w", ErrVerification, ManifestFile, err)
+	}
+	sigData, err := readRaw(SignatureFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to fetch %s: %w", ErrVerification, SignatureFile, err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigData)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid %s: %w", ErrVerification, SignatureFile, err)
+	}
+
+	verified := false
+	for _, k := range v.keys {
+		key, err := ParsePublicKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrVerification, err)
+		}
+		if ed25519.Verify(key, data, sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("%w: %s is not signed by a trusted key", ErrVerification, ManifestFile)
+	}
+
+	var manifest Manifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse %s: %w", ErrVerification, ManifestFile, err)
+	}
+	return manifest.Files, nil
+}
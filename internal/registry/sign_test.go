@@ -0,0 +1,144 @@
+package registry
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testPackage = `name: vimium
+id: dbepggeogbaibhgnhhndojpepiihcmeb
+display_name: Vimium
+`
+
+// newSignedRegistry writes a local registry with one package to a temporary
+// directory and signs it with key.
+func newSignedRegistry(t *testing.T, key ed25519.PrivateKey) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, RegistryFile), "version: 1\npackages:\n  - vimium\n")
+	writeTestFile(t, filepath.Join(dir, PackagesDir, "vimium.yaml"), testPackage)
+	if _, err := Sign(dir, key); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	return dir
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, priv
+}
+
+func TestVerification(t *testing.T) {
+	pub, priv := newTestKey(t)
+	otherPub, _ := newTestKey(t)
+
+	tests := []struct {
+		name string
+		keys []string
+		// modify changes the registry after it is signed.
+		modify  func(t *testing.T, dir string)
+		pkg     string
+		wantErr bool
+	}{
+		{
+			name: "signed by a trusted key",
+			keys: []string{EncodePublicKey(pub)},
+			pkg:  "vimium",
+		},
+		{
+			name: "trusted key among others",
+			keys: []string{EncodePublicKey(otherPub), EncodePublicKey(pub)},
+			pkg:  "vimium",
+		},
+		{
+			name:    "signed by an untrusted key",
+			keys:    []string{EncodePublicKey(otherPub)},
+			pkg:     "vimium",
+			wantErr: true,
+		},
+		{
+			name: "package tampered with",
+			keys: []string{EncodePublicKey(pub)},
+			modify: func(t *testing.T, dir string) {
+				writeTestFile(t, filepath.Join(dir, PackagesDir, "vimium.yaml"), testPackage+"update_url: https://example.com/update.xml\n")
+			},
+			pkg:     "vimium",
+			wantErr: true,
+		},
+		{
+			name: "package added after signing",
+			keys: []string{EncodePublicKey(pub)},
+			modify: func(t *testing.T, dir string) {
+				writeTestFile(t, filepath.Join(dir, RegistryFile), "version: 1\npackages:\n  - vimium\n  - vimium-c\n")
+			},
+			pkg:     "vimium",
+			wantErr: true,
+		},
+		{
+			name: "package file not in the manifest",
+			keys: []string{EncodePublicKey(pub)},
+			modify: func(t *testing.T, dir string) {
+				writeTestFile(t, filepath.Join(dir, PackagesDir, "other.yaml"), "name: other\nid: aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\ndisplay_name: Other\n")
+			},
+			pkg:     "other",
+			wantErr: true,
+		},
+		{
+			name: "signature missing",
+			keys: []string{EncodePublicKey(pub)},
+			modify: func(t *testing.T, dir string) {
+				if err := os.Remove(filepath.Join(dir, SignatureFile)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			pkg:     "vimium",
+			wantErr: true,
+		},
+		{
+			name: "no trusted keys",
+			modify: func(t *testing.T, dir string) {
+				writeTestFile(t, filepath.Join(dir, PackagesDir, "vimium.yaml"), testPackage+"description: unsigned\n")
+			},
+			pkg: "vimium",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newSignedRegistry(t, priv)
+			if tt.modify != nil {
+				tt.modify(t, dir)
+			}
+
+			f := NewLocalFetcher(dir, WithPublicKeys(tt.keys...))
+			_, err := f.FetchRegistry()
+			if err == nil {
+				_, err = f.FetchPackage(tt.pkg)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetching %s: error = %v, wantErr %v", tt.pkg, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrVerification) {
+				t.Errorf("fetching %s: error = %v, want ErrVerification", tt.pkg, err)
+			}
+		})
+	}
+}
@@ -1,27 +1,308 @@
 package registry
 
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+)
+
 // Package represents a Chrome extension package in the registry.
 type Package struct {
-	Name        string   `yaml:"name"`
-	ID          string   `yaml:"id"`
-	DisplayName string   `yaml:"display_name"`
-	Description string   `yaml:"description,omitempty"`
-	Homepage    string   `yaml:"homepage,omitempty"`
-	Repository  string   `yaml:"repository,omitempty"`
-	Tags        []string `yaml:"tags,omitempty"`
+	Name        string   `yaml:"name" json:"name"`
+	ID          string   `yaml:"id" json:"id"`
+	DisplayName string   `yaml:"display_name" json:"display_name"`
+	Description string   `yaml:"description,omitempty" json:"description,omitempty"`
+	Homepage    string   `yaml:"homepage,omitempty" json:"homepage,omitempty"`
+	Repository  string   `yaml:"repository,omitempty" json:"repository,omitempty"`
+	Tags        []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+
+	// UpdateURL is the update manifest URL of a self-hosted extension.
+	// Extensions without one are installed from the Chrome Web Store.
+	UpdateURL string `yaml:"update_url,omitempty" json:"update_url,omitempty"`
+
+	// ChromeWebStore and EdgeAddons are the store listings of extensions
+	// published under different IDs per store. ID and UpdateURL serve as
+	// the Chrome Web Store listing when ChromeWebStore is not set.
+	ChromeWebStore *Listing `yaml:"chrome_web_store,omitempty" json:"chrome_web_store,omitempty"`
+	EdgeAddons     *Listing `yaml:"edge_addons,omitempty" json:"edge_addons,omitempty"`
+	// Firefox is the addons.mozilla.org listing of the extension.
+	Firefox *FirefoxListing `yaml:"firefox,omitempty" json:"firefox,omitempty"`
+
+	// Aliases are alternative names that resolve to this package.
+	Aliases []string `yaml:"aliases,omitempty" json:"aliases,omitempty"`
+	// Deprecated marks a package that should no longer be installed.
+	Deprecated         bool   `yaml:"deprecated,omitempty" json:"deprecated,omitempty"`
+	DeprecationMessage string `yaml:"deprecation_message,omitempty" json:"deprecation_message,omitempty"`
+	// ReplacedBy names the package that supersedes a deprecated package.
+	ReplacedBy string `yaml:"replaced_by,omitempty" json:"replaced_by,omitempty"`
+
+	// Registry is the name of the registry the package was resolved from.
+	Registry string `yaml:"-" json:"registry,omitempty"`
+	// Digest is the content digest of the package file.
+	Digest string `yaml:"-" json:"digest,omitempty"`
+}
+
+// Listing is the listing of an extension in an extension store.
+type Listing struct {
+	ID string `yaml:"id" json:"id"`
+	// UpdateURL defaults to the update URL of the store.
+	UpdateURL string `yaml:"update_url,omitempty" json:"update_url,omitempty"`
+}
+
+// validate checks the listing, if any.
+func (l *Listing) validate() error {
+	if l == nil {
+		return nil
+	}
+	if err := ValidateID(l.ID); err != nil {
+		return err
+	}
+	if l.UpdateURL != "" {
+		if _, err := parseUpdateURL(l.UpdateURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FirefoxListing is the listing of an extension on addons.mozilla.org.
+type FirefoxListing struct {
+	AddonID string `yaml:"addon_id" json:"addon_id"`
+	Slug    string `yaml:"slug" json:"slug"`
+}
+
+// firefoxIDPattern matches Firefox add-on IDs: a GUID in braces or an
+// email-like ID.
+var firefoxIDPattern = regexp.MustCompile(`^(\{[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}\}|[A-Za-z0-9._+-]*@[A-Za-z0-9._-]+)$`)
+
+// slugPattern matches addons.mozilla.org slugs.
+var slugPattern = regexp.MustCompile(`^[^/?#%\s]+$`)
+
+// validate checks the listing, if any.
+func (l *FirefoxListing) validate() error {
+	if l == nil {
+		return nil
+	}
+	if err := ValidateFirefoxID(l.AddonID); err != nil {
+		return err
+	}
+	if !slugPattern.MatchString(l.Slug) {
+		return fmt.Errorf("invalid slug %q", l.Slug)
+	}
+	return nil
+}
+
+// InstallURL returns the addons.mozilla.org URL of the latest version.
+func (l *FirefoxListing) InstallURL() string {
+	return fmt.Sprintf("%s/%s/latest.xpi", AMODownloadURL, l.Slug)
+}
+
+// ValidateFirefoxID checks that id is a well-formed Firefox add-on ID.
+func ValidateFirefoxID(id string) error {
+	if !firefoxIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid Firefox add-on ID %q: must be a GUID in braces or look like name@domain", id)
+	}
+	return nil
 }
 
+// Extension stores.
+const (
+	StoreChromeWebStore = "chrome_web_store"
+	StoreEdgeAddons     = "edge_addons"
+	StoreFirefox        = "firefox"
+)
+
 // Registry represents the registry index.
 type Registry struct {
 	Version  int      `yaml:"version"`
 	Packages []string `yaml:"packages"`
+	// Aliases maps every package alias to the name of its package, so
+	// that clients resolve aliases without fetching each package.
+	Aliases map[string]string `yaml:"aliases,omitempty"`
+}
+
+// Index is the bundled index of a version 2 registry.
+// It carries the full metadata of every package, so the whole registry
+// can be loaded with a single request.
+type Index struct {
+	Version  int        `yaml:"version"`
+	Packages []*Package `yaml:"packages"`
+	// Digests maps each package name to the digest of its package file.
+	Digests map[string]string `yaml:"digests,omitempty"`
+}
+
+// Registry format versions.
+const (
+	// RegistryV1 lists package names in registry.yaml and stores each
+	// package in pkgs/<name>.yaml.
+	RegistryV1 = 1
+	// RegistryV2 additionally publishes a bundled index.yaml.
+	RegistryV2 = 2
+)
+
+// IDLength is the length of a Chrome extension ID.
+const IDLength = 32
+
+// ValidateID checks that id is a well-formed extension ID:
+// 32 characters in the range a-p.
+func ValidateID(id string) error {
+	if len(id) != IDLength {
+		return fmt.Errorf("invalid extension ID %q: must be %d characters, got %d", id, IDLength, len(id))
+	}
+	for _, c := range id {
+		if c < 'a' || c > 'p' {
+			return fmt.Errorf("invalid extension ID %q: must contain only characters a-p", id)
+		}
+	}
+	return nil
+}
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// ValidateName checks that name is a valid package name: lowercase letters,
+// digits and hyphens, starting with a letter or digit.
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid package name %q: use lowercase letters, digits and hyphens", name)
+	}
+	return nil
+}
+
+// Validate checks that the package has the required fields.
+func (p *Package) Validate() error {
+	if err := ValidateName(p.Name); err != nil {
+		return err
+	}
+	if err := ValidateID(p.ID); err != nil {
+		return err
+	}
+	if p.DisplayName == "" {
+		return fmt.Errorf("package %s: display_name is required", p.Name)
+	}
+	for _, alias := range p.Aliases {
+		if err := ValidateName(alias); err != nil {
+			return fmt.Errorf("package %s: alias: %w", p.Name, err)
+		}
+	}
+	if p.Homepage != "" && !isHTTPURL(p.Homepage) {
+		return fmt.Errorf("package %s: homepage %q must be an http(s) URL", p.Name, p.Homepage)
+	}
+	if p.Repository != "" && !isHTTPURL(p.Repository) {
+		return fmt.Errorf("package %s: repository %q must be an http(s) URL", p.Name, p.Repository)
+	}
+	if p.UpdateURL != "" {
+		if _, err := parseUpdateURL(p.UpdateURL); err != nil {
+			return fmt.Errorf("package %s: %w", p.Name, err)
+		}
+	}
+	if err := p.ChromeWebStore.validate(); err != nil {
+		return fmt.Errorf("package %s: %s: %w", p.Name, StoreChromeWebStore, err)
+	}
+	if err := p.EdgeAddons.validate(); err != nil {
+		return fmt.Errorf("package %s: %s: %w", p.Name, StoreEdgeAddons, err)
+	}
+	if err := p.Firefox.validate(); err != nil {
+		return fmt.Errorf("package %s: %s: %w", p.Name, StoreFirefox, err)
+	}
+	if p.ReplacedBy == p.Name && p.Name != "" {
+		return fmt.Errorf("package %s: replaced_by must name another package", p.Name)
+	}
+	return nil
+}
+
+// EffectiveUpdateURL returns the update URL the extension is installed from.
+func (p *Package) EffectiveUpdateURL() string {
+	if p.UpdateURL != "" {
+		return p.UpdateURL
+	}
+	return CRXUpdateURL
+}
+
+// Listing returns the listing of the package in store, with the update URL
+// filled in. ok is false if the package has no listing for store, in which
+// case the Chrome Web Store listing is returned as a fallback. Self-hosted
+// packages are installed from their own update URL in every store.
+func (p *Package) Listing(store string) (listing Listing, ok bool) {
+	switch {
+	case store == StoreEdgeAddons && p.EdgeAddons != nil:
+		listing = *p.EdgeAddons
+		if listing.UpdateURL == "" {
+			listing.UpdateURL = EdgeUpdateURL
+		}
+		return listing, true
+	case p.ChromeWebStore != nil:
+		listing = *p.ChromeWebStore
+		if listing.UpdateURL == "" {
+			listing.UpdateURL = CRXUpdateURL
+		}
+	default:
+		listing = Listing{ID: p.ID, UpdateURL: p.EffectiveUpdateURL()}
+	}
+	return listing, store == StoreChromeWebStore || p.UpdateURL != ""
+}
+
+// ValidateUpdateURL checks that rawURL is an HTTPS URL. Plain HTTP is
+// accepted only for hosts listed in insecureHosts.
+func ValidateUpdateURL(rawURL string, insecureHosts []string) error {
+	u, err := parseUpdateURL(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "http" && !slices.Contains(insecureHosts, u.Hostname()) {
+		return fmt.Errorf("update_url %q must use https; add %s to settings.insecure_update_hosts to allow it", rawURL, u.Hostname())
+	}
+	return nil
+}
+
+// parseUpdateURL parses an absolute http(s) update URL.
+func parseUpdateURL(rawURL string) (*url.URL, error) {
+	if !isHTTPURL(rawURL) {
+		return nil, fmt.Errorf("invalid update_url %q: must be an http(s) URL", rawURL)
+	}
+	return url.Parse(rawURL)
+}
+
+// isHTTPURL reports whether rawURL is an absolute http(s) URL.
+func isHTTPURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+// HasAlias reports whether name is an alias of the package.
+func (p *Package) HasAlias(name string) bool {
+	return slices.Contains(p.Aliases, name)
+}
+
+// DeprecationNotice returns a human-readable deprecation notice, or an
+// empty string if the package is not deprecated.
+func (p *Package) DeprecationNotice() string {
+	if !p.Deprecated {
+		return ""
+	}
+	msg := fmt.Sprintf("%s is deprecated", p.Name)
+	if p.DeprecationMessage != "" {
+		msg += ": " + p.DeprecationMessage
+	}
+	if p.ReplacedBy != "" {
+		msg += fmt.Sprintf(" (replaced by %s; run 'crx migrate')", p.ReplacedBy)
+	}
+	return msg
 }
 
 // CRXUpdateURL is the Chrome Web Store update URL.
 const CRXUpdateURL = "https://clients2.google.com/service/update2/crx"
 
+// EdgeUpdateURL is the Microsoft Edge Add-ons update URL.
+const EdgeUpdateURL = "https://edge.microsoft.com/extensionwebstorebase/v1/crx"
+
+// AMODownloadURL is the base URL of the latest add-on downloads on
+// addons.mozilla.org.
+const AMODownloadURL = "https://addons.mozilla.org/firefox/downloads/latest"
+
 // Default registry configuration.
 const (
+	DefaultRegistryName = "standard"
 	DefaultRegistryRepo = "sivchari/crx-registry"
 	DefaultRegistryRef  = "main"
 )
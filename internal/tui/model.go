@@ -137,27 +137,14 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m *Model) filterPackages() {
-	query := strings.ToLower(m.textInput.Value())
-	if query == "" {
+	query := m.textInput.Value()
+	if strings.TrimSpace(query) == "" {
 		m.filtered = m.packages
 		m.cursor = 0
 		return
 	}
 
-	m.filtered = make([]*registry.Package, 0)
-	for _, pkg := range m.packages {
-		if strings.Contains(strings.ToLower(pkg.Name), query) ||
-			strings.Contains(strings.ToLower(pkg.DisplayName), query) {
-			m.filtered = append(m.filtered, pkg)
-			continue
-		}
-		for _, tag := range pkg.Tags {
-			if strings.Contains(strings.ToLower(tag), query) {
-				m.filtered = append(m.filtered, pkg)
-				break
-			}
-		}
-	}
+	m.filtered = registry.Rank(m.packages, query)
 	m.cursor = 0
 }
 